@@ -13,6 +13,7 @@ import (
 	"strings"
 	"syscall"
 	"time"
+	_ "time/tzdata" // Job timezones must resolve on hosts without a zoneinfo database
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -50,7 +51,8 @@ func CORSMiddleware() gin.HandlerFunc {
 		}
 
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, If-Match")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "ETag")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, PATCH")
 
 		if c.Request.Method == "OPTIONS" {
@@ -110,6 +112,12 @@ func NewRouter(server *gin.Engine, db *config.Database) {
 		log.Fatal("Failed to start River client: ", err)
 	}
 
+	// ===== PUBLIC:: webhook routings ====== //
+	// Registered before CORS: hooks are called by servers that send no Origin
+	webhookHandler := handlers.NewWebhookHandler(services.NewWebhookService(db))
+
+	server.POST("/api/hooks/:token", webhookHandler.TriggerWebhook)
+
 	// Default CORS configuration
 	server.Use(CORSMiddleware())
 	server.Use(gin.Logger())
@@ -119,16 +127,56 @@ func NewRouter(server *gin.Engine, db *config.Database) {
 
 	// ===== PROTECTED:: job routings ====== //
 	jobService := services.NewJobService(db)
+	tasksService := services.NewTasksService(db)
 	jobHandler := handlers.NewJobHandler(jobService)
 
+	taskEventBroker := services.NewTaskEventBroker(db)
+	taskEventBroker.Start(context.Background())
+	jobEventHandler := handlers.NewJobEventHandler(jobService, tasksService, taskEventBroker)
+
 	jobRouter := router.Group("/jobs", middleware.JWTAuthMiddleware())
 
 	jobRouter.POST("", jobHandler.CreateJob)
+	jobRouter.POST("/validate", jobHandler.ValidateJob)
 	jobRouter.GET("", jobHandler.GetJobs)
 	jobRouter.GET("/:id", jobHandler.GetJob)
+	jobRouter.GET("/:id/events", jobEventHandler.StreamJobEvents)
+	jobRouter.GET("/:id/next-runs", jobHandler.GetNextRuns)
+	jobRouter.GET("/:id/tasks/:taskId/steps", jobHandler.GetTaskSteps)
+	jobRouter.PATCH("/:id", CustomizeRateLimiter(1, 5), jobHandler.UpdateJob)
+	jobRouter.POST("/:id/run", CustomizeRateLimiter(1, 5), jobHandler.RunJob)
 	jobRouter.PATCH("/:id/pause", CustomizeRateLimiter(1, 5), jobHandler.PauseJob)
 	jobRouter.PATCH("/:id/resume", CustomizeRateLimiter(1, 5), jobHandler.ResumeJob)
 	jobRouter.DELETE("/:id", jobHandler.DeleteJob)
+
+	// ===== PROTECTED:: task routings ====== //
+	taskHandler := handlers.NewTaskHandler(jobService)
+
+	taskRouter := router.Group("/tasks", middleware.JWTAuthMiddleware())
+
+	taskRouter.GET("/:id", taskHandler.GetTask)
+	taskRouter.POST("/:id/retry", CustomizeRateLimiter(1, 5), taskHandler.RetryTask)
+	taskRouter.POST("/:id/cancel", CustomizeRateLimiter(1, 5), taskHandler.CancelTask)
+	taskRouter.POST("/:id/resume", CustomizeRateLimiter(1, 5), taskHandler.ResumeTask)
+
+	// ===== PROTECTED:: calendar routings ====== //
+	calendarHandler := handlers.NewCalendarHandler(services.NewCalendarService(db))
+
+	calendarRouter := router.Group("/calendars", middleware.JWTAuthMiddleware())
+
+	calendarRouter.POST("", calendarHandler.CreateCalendar)
+	calendarRouter.GET("", calendarHandler.GetCalendars)
+	calendarRouter.GET("/:id", calendarHandler.GetCalendar)
+	calendarRouter.PUT("/:id", CustomizeRateLimiter(1, 5), calendarHandler.UpdateCalendar)
+	calendarRouter.DELETE("/:id", calendarHandler.DeleteCalendar)
+
+	// ===== PROTECTED:: workspace routings ====== //
+	workspaceHandler := handlers.NewWorkspaceHandler(services.NewWorkspaceService(db))
+
+	workspaceRouter := router.Group("/workspaces", middleware.JWTAuthMiddleware())
+
+	workspaceRouter.GET("/:id/settings", workspaceHandler.GetSettings)
+	workspaceRouter.PUT("/:id/settings", CustomizeRateLimiter(1, 5), workspaceHandler.UpdateSettings)
 }
 
 // Main function
@@ -10,6 +10,7 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata" // Job timezones must resolve on hosts without a zoneinfo database
 
 	"github.com/joho/godotenv"
 )
@@ -36,6 +37,19 @@ func main() {
 		log.Fatal("Failed to create River client: ", err)
 	}
 
+	jobService := services.NewJobService(db)
+	taskService := services.NewTasksService(db)
+
+	// ✅ ADD: Recover tasks first
+	if err := recoverRunningTasks(context.Background(), taskService); err != nil {
+		log.Printf("Error recovering running tasks: %v", err)
+	}
+
+	// Then recover missed jobs, before River starts running their stale scheduled runs
+	if err := recoverMissedJobs(context.Background(), jobService); err != nil {
+		log.Printf("Error recovering missed jobs: %v", err)
+	}
+
 	// Start River client
 	log.Println("Starting River client...")
 	if err := riverClient.Start(context.Background()); err != nil {
@@ -44,23 +58,6 @@ func main() {
 
 	log.Println("Worker started successfully")
 
-	// Start interval job scheduler
-	jobService := services.NewJobService(db)
-	taskService := services.NewTasksService(db)
-	go func() {
-		// Wait a bit for River to fully initialize
-		time.Sleep(5 * time.Second)
-		
-		// ✅ ADD: Recover tasks first
-		if err := recoverRunningTasks(context.Background(), taskService); err != nil {
-			log.Printf("Error recovering running tasks: %v", err)
-		}
-		
-		// Then recover missed jobs
-		if err := recoverMissedJobs(context.Background(), jobService); err != nil {
-			log.Printf("Error recovering missed jobs: %v", err)
-		}
-	}()
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -92,20 +89,27 @@ func recoverMissedJobs(ctx context.Context, jobService *services.JobService) err
 	recoveredCount := 0
 
 	for _, job := range jobs {
-		// Skip non-interval jobs or inactive jobs
-		if job.Type != models.JobTypeInterval || job.Status != models.JobStatusActive || job.IsDeleted {
+		// Skip inactive jobs
+		if job.Status != models.JobStatusActive || job.IsDeleted {
 			continue
 		}
 
-		// Check if job should have run but missed
-		if job.NextRunAt != nil && job.NextRunAt.Before(now) {
-			log.Printf("Recovering missed job: %s (should have run at %v)", job.ID, job.NextRunAt)
+		// Apply the misfire policy to runs missed while the worker was down,
+		// this also reschedules interval jobs
+		recovered, err := jobService.RecoverMissedRuns(ctx, &job, now)
+		if err != nil {
+			log.Printf("Failed to recover job %s: %v", job.ID, err)
+			continue
+		}
+		if recovered {
+			recoveredCount++
+		}
 
-			// Reschedule immediately or for next scheduled time
-			if err := jobService.RescheduleIntervalJob(ctx, &job); err != nil {
-				log.Printf("Failed to recover job %s: %v", job.ID, err)
-			} else {
-				recoveredCount++
+		// Complete interval jobs whose window closed or whose max_runs were used while the worker was down
+		if job.Type == models.JobTypeInterval && job.Status == models.JobStatusActive && services.JobRunLimitReached(&job, now) {
+			log.Printf("Completing job %s: no run left in its window or max_runs", job.ID)
+			if err := jobService.CompleteJob(ctx, &job); err != nil {
+				log.Printf("Failed to complete job %s: %v", job.ID, err)
 			}
 		}
 	}
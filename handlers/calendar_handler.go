@@ -0,0 +1,158 @@
+// Controller for calendar related endpoints
+package handlers
+
+import (
+	"errors"
+	"gin-gorm-river-app/models"
+	"gin-gorm-river-app/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type CalendarHandler struct {
+	calendarService *services.CalendarService
+}
+
+func NewCalendarHandler(calendarService *services.CalendarService) *CalendarHandler {
+	return &CalendarHandler{
+		calendarService: calendarService,
+	}
+}
+
+func (h *CalendarHandler) CreateCalendar(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.CalendarRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	calendar, err := h.calendarService.CreateCalendar(c, &req, uuid.MustParse(userID))
+	if err != nil {
+		if isValidationError(err) {
+			c.JSON(http.StatusBadRequest, invalidRequestBody(err))
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, calendar)
+}
+
+// GetCalendars returns the calendars of a workspace
+func (h *CalendarHandler) GetCalendars(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	workspaceID, err := uuid.Parse(c.Query("workspace_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid workspace ID"})
+		return
+	}
+
+	calendars, err := h.calendarService.GetCalendars(c, uuid.MustParse(userID), workspaceID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": calendars})
+}
+
+func (h *CalendarHandler) GetCalendar(c *gin.Context) {
+	calendarID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid calendar ID"})
+		return
+	}
+
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	calendar, err := h.calendarService.GetCalendar(c, calendarID, uuid.MustParse(userID))
+	if err != nil {
+		if errors.Is(err, services.ErrCalendarNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, calendar)
+}
+
+// UpdateCalendar replaces the name, timezone, dates and ranges of a calendar
+func (h *CalendarHandler) UpdateCalendar(c *gin.Context) {
+	calendarID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid calendar ID"})
+		return
+	}
+
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.CalendarRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	calendar, err := h.calendarService.UpdateCalendar(c, calendarID, uuid.MustParse(userID), &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrCalendarNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case isValidationError(err):
+			c.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, calendar)
+}
+
+func (h *CalendarHandler) DeleteCalendar(c *gin.Context) {
+	calendarID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid calendar ID"})
+		return
+	}
+
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := h.calendarService.DeleteCalendar(c, calendarID, uuid.MustParse(userID)); err != nil {
+		if errors.Is(err, services.ErrCalendarNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Calendar deleted successfully"})
+}
@@ -0,0 +1,97 @@
+// Controller for streaming job task events
+package handlers
+
+import (
+	"errors"
+	"gin-gorm-river-app/services"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// eventsHeartbeatInterval keeps idle SSE connections open through proxies
+const eventsHeartbeatInterval = 15 * time.Second
+
+type JobEventHandler struct {
+	jobService   *services.JobService
+	tasksService *services.TasksService
+	broker       *services.TaskEventBroker
+}
+
+func NewJobEventHandler(jobService *services.JobService, tasksService *services.TasksService, broker *services.TaskEventBroker) *JobEventHandler {
+	return &JobEventHandler{
+		jobService:   jobService,
+		tasksService: tasksService,
+		broker:       broker,
+	}
+}
+
+// StreamJobEvents streams task state transitions and partial results of a job as Server-Sent Events
+func (h *JobEventHandler) StreamJobEvents(c *gin.Context) {
+	userID := c.GetString("user_id")
+	workspaceID := c.Query("workspace_id")
+	if userID == "" || workspaceID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+
+	userUUID, err := uuid.Parse(userID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	job, err := h.jobService.GetOwnedJob(c, jobID, userUUID)
+	if err != nil {
+		if errors.Is(err, services.ErrJobNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if job.WorkspaceID.String() != workspaceID {
+		c.JSON(http.StatusNotFound, gin.H{"error": services.ErrJobNotFound.Error()})
+		return
+	}
+
+	events, unsubscribe := h.broker.Subscribe(job.ID)
+	defer unsubscribe()
+
+	heartbeat := time.NewTicker(eventsHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.SSEvent("ready", gin.H{"job_id": job.ID})
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-heartbeat.C:
+			c.SSEvent("ping", gin.H{"time": time.Now()})
+			return true
+		case event := <-events:
+			task, err := h.tasksService.GetTaskByJobID(event.TaskID, job.ID)
+			if err != nil {
+				log.Printf("Failed to load task %s for event stream: %v", event.TaskID, err)
+				return true
+			}
+			c.SSEvent(event.Event, task)
+			return true
+		}
+	})
+}
@@ -2,13 +2,17 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"gin-gorm-river-app/models"
 	"gin-gorm-river-app/services"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type JobHandler struct {
@@ -36,11 +40,38 @@ func (h *JobHandler) CreateJob(c *gin.Context) {
 
 	job, err := h.jobService.CreateJob(c, &req, userID)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidJobRequest) {
+			c.JSON(http.StatusBadRequest, invalidRequestBody(err))
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusCreated, job)
+	c.JSON(http.StatusCreated, models.CreatedJobResponse{Jobs: job, WebhookSecret: job.WebhookSecret})
+}
+
+// ValidateJob checks a job request and previews its next runs without creating the job
+func (h *JobHandler) ValidateJob(c *gin.Context) {
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req models.CreateJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resp, err := h.jobService.ValidateJob(&req, uuid.MustParse(userID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
 }
 
 // GetJobs returns all jobs for a user
@@ -65,9 +96,18 @@ func (h *JobHandler) GetJobs(c *gin.Context) {
 		limitInt = 10
 	}
 
+	status := models.JobStatus(c.Query("status"))
+	switch status {
+	case "", models.JobStatusActive, models.JobStatusInactive, models.JobStatusCompleted:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be one of active, inactive, completed"})
+		return
+	}
+
 	jobs, err := h.jobService.GetJobs(c, &services.GetJobsRequest{
 		UserId:      userID,
 		WorkspaceId: workspaceID,
+		Status:      status,
 		Page:        pageInt,
 		Limit:       limitInt,
 	})
@@ -111,6 +151,16 @@ func (h *JobHandler) GetJob(c *gin.Context) {
 		TaskPage:  taskPageInt,
 		TaskLimit: taskLimitInt,
 	})
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": services.ErrJobNotFound.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("ETag", jobETag(resp.Job))
 	// Create response with job and paginated tasks
 	response := gin.H{
 		"job":   resp.Job,
@@ -120,6 +170,162 @@ func (h *JobHandler) GetJob(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// UpdateJob edits a job. The If-Match header must carry the ETag returned by GetJob.
+func (h *JobHandler) UpdateJob(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	version, ok := parseIfMatchVersion(c.GetHeader("If-Match"))
+	if !ok {
+		c.JSON(http.StatusPreconditionRequired, gin.H{"error": "If-Match header with the job version is required"})
+		return
+	}
+
+	var req models.UpdateJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, err := h.jobService.UpdateJob(c, jobID, uuid.MustParse(userID), version, &req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrJobNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrJobVersionConflict):
+			c.JSON(http.StatusPreconditionFailed, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrInvalidJobRequest):
+			c.JSON(http.StatusBadRequest, invalidRequestBody(err))
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.Header("ETag", jobETag(job))
+	c.JSON(http.StatusOK, job)
+}
+
+// RunJob enqueues an immediate execution of a job without touching its schedule
+func (h *JobHandler) RunJob(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	riverJobID, err := h.jobService.RunJobNow(c, jobID, uuid.MustParse(userID))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrJobNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrJobNotActive):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Job run enqueued", "river_job_id": riverJobID})
+}
+
+// GetNextRuns returns the next run times of a job in its timezone
+func (h *JobHandler) GetNextRuns(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	count, err := strconv.Atoi(c.DefaultQuery("count", "5"))
+	if err != nil || count < 1 || count > services.MaxNextRunsPreview {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("count must be between 1 and %d", services.MaxNextRunsPreview)})
+		return
+	}
+
+	resp, err := h.jobService.GetNextRuns(c, jobID, uuid.MustParse(userID), count)
+	if err != nil {
+		if errors.Is(err, services.ErrJobNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetTaskSteps returns the agent plan steps recorded for a task of a job
+func (h *JobHandler) GetTaskSteps(c *gin.Context) {
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+	taskID, err := uuid.Parse(c.Param("taskId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	userID := c.GetString("user_id")
+	if userID == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	steps, err := h.jobService.GetTaskSteps(c, jobID, taskID, uuid.MustParse(userID))
+	if err != nil {
+		if errors.Is(err, services.ErrJobNotFound) || errors.Is(err, services.ErrTaskNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": steps})
+}
+
+// jobETag formats the job version as a strong ETag
+func jobETag(job *models.Jobs) string {
+	return strconv.Quote(strconv.FormatInt(job.Version, 10))
+}
+
+// parseIfMatchVersion extracts the job version from an If-Match header value
+func parseIfMatchVersion(header string) (int64, bool) {
+	value := strings.TrimPrefix(strings.TrimSpace(header), "W/")
+	value = strings.Trim(value, `"`)
+	version, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || version < 1 {
+		return 0, false
+	}
+	return version, true
+}
+
 func (h *JobHandler) DeleteJob(c *gin.Context) {
 	jobID := c.Param("id")
 	if jobID == "" {
@@ -146,9 +352,9 @@ func (h *JobHandler) DeleteJob(c *gin.Context) {
 }
 
 func (h *JobHandler) PauseJob(c *gin.Context) {
-	jobID := c.Param("id")
-	if jobID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Job ID is required"})
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
 		return
 	}
 
@@ -158,9 +364,16 @@ func (h *JobHandler) PauseJob(c *gin.Context) {
 		return
 	}
 
-	err := h.jobService.PauseJob(c, uuid.MustParse(jobID), uuid.MustParse(userID))
+	err = h.jobService.PauseJob(c, jobID, uuid.MustParse(userID))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		switch {
+		case errors.Is(err, services.ErrJobNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrJobScheduleElapsed):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
@@ -168,9 +381,9 @@ func (h *JobHandler) PauseJob(c *gin.Context) {
 }
 
 func (h *JobHandler) ResumeJob(c *gin.Context) {
-	jobID := c.Param("id")
-	if jobID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Job ID is required"})
+	jobID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
 		return
 	}
 
@@ -180,9 +393,16 @@ func (h *JobHandler) ResumeJob(c *gin.Context) {
 		return
 	}
 
-	err := h.jobService.ResumeJob(c, uuid.MustParse(jobID), uuid.MustParse(userID))
+	err = h.jobService.ResumeJob(c, jobID, uuid.MustParse(userID))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		switch {
+		case errors.Is(err, services.ErrJobNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrJobScheduleElapsed):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
 		return
 	}
 
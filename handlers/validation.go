@@ -0,0 +1,24 @@
+package handlers
+
+import (
+	"errors"
+	"gin-gorm-river-app/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// isValidationError reports whether err lists invalid fields of the request
+func isValidationError(err error) bool {
+	var verr *services.ValidationError
+	return errors.As(err, &verr)
+}
+
+// invalidRequestBody returns the error response of a rejected request, with field errors when known
+func invalidRequestBody(err error) gin.H {
+	body := gin.H{"error": err.Error()}
+	var verr *services.ValidationError
+	if errors.As(err, &verr) {
+		body["errors"] = verr.Errors
+	}
+	return body
+}
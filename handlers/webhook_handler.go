@@ -0,0 +1,59 @@
+// Controller for inbound webhook endpoints
+package handlers
+
+import (
+	"errors"
+	"gin-gorm-river-app/models"
+	"gin-gorm-river-app/services"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookHandler struct {
+	webhookService *services.WebhookService
+}
+
+func NewWebhookHandler(webhookService *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+	}
+}
+
+// TriggerWebhook enqueues a run of the webhook job owning the token of the URL.
+// Requests are authenticated by their X-Webhook-Signature instead of a JWT.
+func (h *WebhookHandler) TriggerWebhook(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, services.MaxWebhookBodySize))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body too large"})
+		return
+	}
+
+	request := &models.WebhookRequest{
+		RemoteAddr:  c.ClientIP(),
+		UserAgent:   c.Request.UserAgent(),
+		ContentType: c.ContentType(),
+		DeliveryID:  c.GetHeader("X-Webhook-Delivery"),
+		BodySize:    len(body),
+		ReceivedAt:  time.Now(),
+	}
+
+	riverJobID, err := h.webhookService.TriggerWebhook(c, c.Param("token"), c.GetHeader("X-Webhook-Timestamp"), c.GetHeader("X-Webhook-Signature"), request, body)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrWebhookNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrWebhookSignature):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrWebhookRateLimited):
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Job run enqueued", "river_job_id": riverJobID})
+}
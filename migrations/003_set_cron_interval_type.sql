@@ -0,0 +1,12 @@
+-- Migration 003: Set interval_type to cron on interval jobs whose value is a cron expression
+-- Jobs created before the cron interval type stored cron expressions under minutes, hours,
+-- days or months. This migration is handled by MigrateCronIntervals in migrate.go.
+
+-- Manual SQL equivalent (for reference only):
+-- UPDATE jobs SET "interval" = jsonb_set("interval"::jsonb, '{interval_type}', '"cron"')::text
+--     WHERE type = 'interval' AND "interval" IS NOT NULL
+--     AND COALESCE("interval"::jsonb ->> 'interval_type', '') <> 'cron'
+--     AND "interval"::jsonb ->> 'value' !~ '^\s*[0-9]+\s*$';
+
+-- Note: This file is for reference only. The actual migration is handled by GORM setup
+-- when you run: go run cmd/migrate/main.go -action=setup
@@ -15,6 +15,10 @@ func RunAutoMigrations(db *gorm.DB) error {
 	err := db.AutoMigrate(
 		&models.Jobs{},
 		&models.Tasks{},
+		&models.TaskAttempts{},
+		&models.TaskSteps{},
+		&models.Calendars{},
+		&models.WorkspaceSettings{},
 	)
 
 	if err != nil {
@@ -26,6 +30,22 @@ func RunAutoMigrations(db *gorm.DB) error {
 	return nil
 }
 
+// MigrateCronIntervals sets interval_type to cron on interval jobs whose value is a cron expression.
+// Jobs created before the cron interval type stored their expression under any interval_type.
+func MigrateCronIntervals(db *gorm.DB) error {
+	query := `UPDATE jobs SET "interval" = jsonb_set("interval"::jsonb, '{interval_type}', '"cron"')::text
+		WHERE type = 'interval' AND "interval" IS NOT NULL
+		AND COALESCE("interval"::jsonb ->> 'interval_type', '') <> 'cron'
+		AND "interval"::jsonb ->> 'value' !~ '^\s*[0-9]+\s*$'`
+	result := db.Exec(query)
+	if result.Error != nil {
+		log.Printf("Failed to migrate cron intervals: %v", result.Error)
+		return result.Error
+	}
+	log.Printf("Migrated %d cron intervals", result.RowsAffected)
+	return nil
+}
+
 // SetupDatabase runs all necessary database setup
 func SetupDatabase(db *gorm.DB) error {
 	// Run auto-migrations
@@ -33,5 +53,10 @@ func SetupDatabase(db *gorm.DB) error {
 		return err
 	}
 
+	// Run data migrations
+	if err := MigrateCronIntervals(db); err != nil {
+		return err
+	}
+
 	return nil
 }
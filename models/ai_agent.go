@@ -8,8 +8,9 @@ type AIAgentData struct {
 }
 
 type ClientAgentData struct {
-	ID          string `json:"id" validate:"required"`
-	Name        string `json:"name" validate:"required"`
-	Description string `json:"description" validate:"required"`
-	URL         string `json:"url" validate:"required,url"`
+	ID               string `json:"id" validate:"required"`
+	Name             string `json:"name" validate:"required"`
+	Description      string `json:"description" validate:"required"`
+	URL              string `json:"url" validate:"required,url"`
+	MaxParallelSteps int    `json:"max_parallel_steps,omitempty" validate:"omitempty,min=1,max=32"` // Plan steps run at once, default 4
 }
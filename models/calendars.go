@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// BlackoutRange is a recurring daily time range during which no scheduled run happens
+type BlackoutRange struct {
+	Weekdays []string `json:"weekdays,omitempty"` // mon, tue, wed, thu, fri, sat, sun; empty means every day
+	Start    string   `json:"start"`              // HH:MM, inclusive
+	End      string   `json:"end"`                // HH:MM or 24:00, exclusive; before Start the range spans midnight
+}
+
+// Calendars are workspace-level sets of excluded dates and blackout ranges referenced by jobs
+type Calendars struct {
+	ID             uuid.UUID       `gorm:"primaryKey" db:"id" json:"id"`
+	Name           string          `gorm:"not null" db:"name" json:"name"`
+	UserID         uuid.UUID       `gorm:"not null" db:"user_id" json:"user_id"`
+	WorkspaceID    uuid.UUID       `gorm:"not null;index" db:"workspace_id" json:"workspace_id"`
+	Timezone       string          `gorm:"not null;default:UTC" db:"timezone" json:"timezone"`                     // IANA timezone of the dates and ranges
+	ExcludedDates  []string        `gorm:"serializer:json;type:jsonb" db:"excluded_dates" json:"excluded_dates"`   // YYYY-MM-DD, or MM-DD for every year
+	BlackoutRanges []BlackoutRange `gorm:"serializer:json;type:jsonb" db:"blackout_ranges" json:"blackout_ranges"` // Recurring blackouts
+	IsDeleted      bool            `gorm:"not null;default:false" db:"is_deleted" json:"is_deleted"`
+	CreatedAt      time.Time       `gorm:"not null" db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time       `gorm:"not null" db:"updated_at" json:"updated_at"`
+}
+
+// Calendar Request DTO of create and replace
+type CalendarRequest struct {
+	Name           string          `json:"name" binding:"required,min=1,max=100"`
+	WorkspaceID    uuid.UUID       `json:"workspace_id" binding:"required"`
+	Timezone       string          `json:"timezone,omitempty"` // Optional IANA timezone, defaults to UTC
+	ExcludedDates  []string        `json:"excluded_dates,omitempty"`
+	BlackoutRanges []BlackoutRange `json:"blackout_ranges,omitempty"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "net/http"
+
+// HTTPRequestData is the resource_data of http_request jobs
+type HTTPRequestData struct {
+	Method         string            `json:"method" validate:"omitempty,oneof=GET POST PUT PATCH DELETE HEAD"` // Default GET
+	URL            string            `json:"url" validate:"required,url"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	Body           string            `json:"body,omitempty"`                                            // Prompt template of the request body
+	ExpectedStatus []int             `json:"expected_status,omitempty" validate:"dive,min=100,max=599"` // Default any 2xx
+	Extract        map[string]string `json:"extract,omitempty"`                                         // Result name -> JSONPath into the response body
+}
+
+// HTTPRequestResult is the task result of http_request jobs
+type HTTPRequestResult struct {
+	StatusCode int                    `json:"status_code"`
+	Headers    http.Header            `json:"headers"`
+	Body       string                 `json:"body"`
+	Extracted  map[string]interface{} `json:"extracted,omitempty"`
+}
@@ -12,9 +12,10 @@ import (
 type JobStatus string
 
 const (
-	JobStatusActive   JobStatus = "active"
-	JobStatusInactive JobStatus = "inactive"
-	JobStatusDeleted  JobStatus = "deleted"
+	JobStatusActive    JobStatus = "active"
+	JobStatusInactive  JobStatus = "inactive"
+	JobStatusDeleted   JobStatus = "deleted"
+	JobStatusCompleted JobStatus = "completed" // ends_at has passed or max_runs are used
 )
 
 type TaskStatus string
@@ -24,13 +25,113 @@ const (
 	TaskStatusRunning   TaskStatus = "running"
 	TaskStatusCompleted TaskStatus = "completed"
 	TaskStatusFailed    TaskStatus = "failed"
+	TaskStatusTimedOut  TaskStatus = "timed_out"
+	TaskStatusCanceled  TaskStatus = "canceled"
+	TaskStatusSkipped   TaskStatus = "skipped"
+	TaskStatusRetrying  TaskStatus = "retrying"
+
+	TaskStatusPartiallyCompleted TaskStatus = "partially_completed" // some steps of its agent plan failed
+)
+
+// OverlapPolicy decides what a run does when the previous run of the same job is still running
+type OverlapPolicy string
+
+const (
+	OverlapPolicySkip    OverlapPolicy = "skip"    // record a skipped task and do nothing
+	OverlapPolicyQueue   OverlapPolicy = "queue"   // wait for the running task to finish
+	OverlapPolicyReplace OverlapPolicy = "replace" // cancel the running task and start
+	OverlapPolicyAllow   OverlapPolicy = "allow"   // run concurrently
+)
+
+// PlanFailurePolicy decides how failed steps of an agent plan affect the plan and its task
+type PlanFailurePolicy string
+
+const (
+	PlanFailurePolicyFailFast   PlanFailurePolicy = "fail_fast"    // cancel the plan and fail the task on the first failed step
+	PlanFailurePolicyBestEffort PlanFailurePolicy = "best_effort"  // run every step it can, fail the task only when none succeeded
+	PlanFailurePolicyMinSuccess PlanFailurePolicy = "min_success=" // followed by N: fail the task when fewer than N steps succeeded
+)
+
+// MisfirePolicy decides what happens to runs missed while the worker was down
+type MisfirePolicy string
+
+const (
+	MisfirePolicySkip         MisfirePolicy = "skip"           // record a skipped task and wait for the next run
+	MisfirePolicyRunOnce      MisfirePolicy = "run_once"       // run once for all the missed runs
+	MisfirePolicyRunAllMissed MisfirePolicy = "run_all_missed" // run every missed run, up to misfire_limit
+)
+
+// TaskTrigger records what started a task
+type TaskTrigger string
+
+const (
+	TaskTriggerSchedule   TaskTrigger = "schedule"
+	TaskTriggerManual     TaskTrigger = "manual"
+	TaskTriggerMisfire    TaskTrigger = "misfire"    // run missed while the worker was down
+	TaskTriggerDependency TaskTrigger = "dependency" // run after a task of an upstream job finished
+	TaskTriggerWebhook    TaskTrigger = "webhook"    // run requested through the job's hook URL
+	TaskTriggerRetry      TaskTrigger = "retry"      // run of the payload of an earlier task, requested through the API
+)
+
+// WebhookRequest is the metadata of the inbound request that triggered a webhook run
+type WebhookRequest struct {
+	RemoteAddr  string    `json:"remote_addr"`
+	UserAgent   string    `json:"user_agent,omitempty"`
+	ContentType string    `json:"content_type,omitempty"`
+	DeliveryID  string    `json:"delivery_id,omitempty"` // X-Webhook-Delivery header of the caller
+	BodySize    int       `json:"body_size"`
+	ReceivedAt  time.Time `json:"received_at"`
+}
+
+// DependencyCondition decides which outcomes of an upstream task trigger a dependent job
+type DependencyCondition string
+
+const (
+	DependencyOnSuccess DependencyCondition = "on_success"
+	DependencyOnFailure DependencyCondition = "on_failure" // failed, timed out or canceled
+	DependencyAlways    DependencyCondition = "always"
 )
 
+// JobDependency is an upstream job whose finished tasks trigger the job
+type JobDependency struct {
+	JobID     uuid.UUID           `json:"job_id"`
+	Condition DependencyCondition `json:"condition"`
+}
+
+// ErrorClass groups run failures for retry decisions
+type ErrorClass string
+
+const (
+	ErrorClassNetwork     ErrorClass = "network"      // connection refused, reset, DNS...
+	ErrorClassTimeout     ErrorClass = "timeout"      // request or run deadline exceeded
+	ErrorClassServerError ErrorClass = "server_error" // HTTP 5xx and 429
+	ErrorClassClientError ErrorClass = "client_error" // HTTP 4xx
+	ErrorClassAgentError  ErrorClass = "agent_error"  // JSON-RPC error returned by the agent
+	ErrorClassOther       ErrorClass = "other"
+)
+
+type BackoffStrategy string
+
+const (
+	BackoffFixed       BackoffStrategy = "fixed"
+	BackoffExponential BackoffStrategy = "exponential"
+)
+
+// RetryPolicy controls how a failed run is retried before its task is marked failed
+type RetryPolicy struct {
+	MaxAttempts     int             `json:"max_attempts"`                // Total attempts including the first one
+	Backoff         BackoffStrategy `json:"backoff,omitempty"`           // Default exponential
+	DelaySeconds    int             `json:"delay_seconds,omitempty"`     // Delay before the first retry, default 30
+	MaxDelaySeconds int             `json:"max_delay_seconds,omitempty"` // Cap of exponential backoff, default 3600
+	RetryOn         []ErrorClass    `json:"retry_on,omitempty"`          // Default network, timeout, server_error
+}
+
 type ResourceName string
 
 const (
 	AIAgent     ResourceName = "ai_agent"
 	ClientAgent ResourceName = "client_agent"
+	HTTPRequest ResourceName = "http_request"
 )
 
 type ScheduleData struct {
@@ -38,15 +139,27 @@ type ScheduleData struct {
 	ExecuteAt *string `json:"execute_at"`
 }
 
+type IntervalType string
+
+const (
+	IntervalTypeMinutes IntervalType = "minutes"
+	IntervalTypeHours   IntervalType = "hours"
+	IntervalTypeDays    IntervalType = "days"
+	IntervalTypeMonths  IntervalType = "months"
+	IntervalTypeCron    IntervalType = "cron"
+)
+
 type IntervalData struct {
-	IntervalType string  `json:"interval_type" validate:"required,oneof=minutes, hours, days, months"`
-	Value        *string `json:"value"`
+	IntervalType IntervalType `json:"interval_type" validate:"required,oneof=minutes hours days months cron"`
+	Value        *string      `json:"value"`              // Number of units, or a cron expression for the cron type
+	StartAt      *string      `json:"start_at,omitempty"` // Anchor of minutes/hours/days/months intervals, defaults to the job creation
 }
 
 type Payload struct {
-	Prompt       string       `json:"prompt" validate:"required"`
-	ResourceName ResourceName `json:"resource_name" validate:"required,oneof=ai_agent client_agent"`
-	ResourceData string       `json:"resource_data" validate:"required"`
+	Prompt         string       `json:"prompt" validate:"required"`
+	ResourceName   ResourceName `json:"resource_name" validate:"required"` // Resource type with a registered executor
+	ResourceData   string       `json:"resource_data" validate:"required"`
+	TimeoutSeconds int          `json:"timeout_seconds,omitempty"` // Overall deadline of one run, 0 = default
 }
 
 type JobType string
@@ -54,51 +167,152 @@ type JobType string
 const (
 	JobTypeScheduled JobType = "scheduled"
 	JobTypeInterval  JobType = "interval"
+	JobTypeDependent JobType = "dependent" // runs only when triggered by its depends_on
+	JobTypeWebhook   JobType = "webhook"   // runs when its hook URL is called
 )
 
 type Jobs struct {
-	ID            uuid.UUID  `gorm:"primaryKey" db:"id" json:"id"`
-	Name          string     `gorm:"not null" db:"name" json:"name" default:"Job"`
-	UserID        uuid.UUID  `gorm:"not null" db:"user_id" json:"user_id"`
-	WorkspaceID   uuid.UUID  `gorm:"not null" db:"workspace_id" json:"workspace_id"`
-	Payload       string     `gorm:"not null" db:"payload" json:"payload"`
-	Status        JobStatus  `gorm:"not null;default:active" db:"status" json:"status"`
-	Type          JobType    `gorm:"not null" db:"type" json:"type"`
-	Schedule      *string    `db:"schedule" json:"schedule"`
-	Interval      *string    `db:"interval" json:"interval"`
-	IsDeleted     bool       `gorm:"not null;default:false" db:"is_deleted" json:"is_deleted"`
-	NextRunAt     *time.Time `json:"next_run_at,omitempty" db:"next_run_at"`
-	LastRunAt     *time.Time `json:"last_run_at,omitempty" db:"last_run_at"`
-	CurrentTaskID *uuid.UUID `json:"current_task_id,omitempty" db:"current_task_id"` // ✅ ADD: Track current task being executed
-	CreatedAt     time.Time  `gorm:"not null" db:"created_at" json:"created_at"`
-	UpdatedAt     time.Time  `gorm:"not null" db:"updated_at" json:"updated_at"`
-	Version       int64      `gorm:"not null" db:"version" json:"version"`
-	RiverJobID    int64      `gorm:"not null" db:"river_job_id" json:"river_job_id"`
+	ID                uuid.UUID         `gorm:"primaryKey" db:"id" json:"id"`
+	Name              string            `gorm:"not null" db:"name" json:"name" default:"Job"`
+	UserID            uuid.UUID         `gorm:"not null" db:"user_id" json:"user_id"`
+	WorkspaceID       uuid.UUID         `gorm:"not null" db:"workspace_id" json:"workspace_id"`
+	Payload           string            `gorm:"not null" db:"payload" json:"payload"`
+	Status            JobStatus         `gorm:"not null;default:active" db:"status" json:"status"`
+	Type              JobType           `gorm:"not null" db:"type" json:"type"`
+	Schedule          *string           `db:"schedule" json:"schedule"`
+	Interval          *string           `db:"interval" json:"interval"`
+	IsDeleted         bool              `gorm:"not null;default:false" db:"is_deleted" json:"is_deleted"`
+	NextRunAt         *time.Time        `json:"next_run_at,omitempty" db:"next_run_at"`
+	LastRunAt         *time.Time        `json:"last_run_at,omitempty" db:"last_run_at"`
+	CurrentTaskID     *uuid.UUID        `json:"current_task_id,omitempty" db:"current_task_id"` // ✅ ADD: Track current task being executed
+	OverlapPolicy     OverlapPolicy     `gorm:"not null;default:allow" db:"overlap_policy" json:"overlap_policy"`
+	RetryPolicy       *string           `db:"retry_policy" json:"retry_policy"`
+	Timezone          string            `gorm:"not null;default:UTC" db:"timezone" json:"timezone"` // IANA timezone of Schedule and Interval
+	StartsAt          *time.Time        `db:"starts_at" json:"starts_at,omitempty"`                 // No scheduled run before this time
+	EndsAt            *time.Time        `db:"ends_at" json:"ends_at,omitempty"`                     // No scheduled run after this time
+	MaxRuns           *int              `db:"max_runs" json:"max_runs,omitempty"`                   // Number of scheduled runs before the job completes
+	RunCount          int               `gorm:"not null;default:0" db:"run_count" json:"run_count"` // Scheduled runs started so far
+	MisfirePolicy     MisfirePolicy     `gorm:"not null;default:skip" db:"misfire_policy" json:"misfire_policy"`
+	MisfireLimit      int               `gorm:"not null;default:0" db:"misfire_limit" json:"misfire_limit"`       // Cap of run_all_missed, 0 means the default
+	CalendarIDs       []uuid.UUID       `gorm:"serializer:json;type:jsonb" db:"calendar_ids" json:"calendar_ids"` // Calendars whose blackouts suppress runs
+	JitterSeconds     *int              `db:"jitter_seconds" json:"jitter_seconds"`                               // Maximum run delay, nil means the workspace spread
+	DependsOn         []JobDependency   `gorm:"serializer:json;type:jsonb" db:"depends_on" json:"depends_on"`     // Upstream jobs triggering this one
+	WebhookToken      *string           `gorm:"uniqueIndex" db:"webhook_token" json:"webhook_token,omitempty"`    // Path token of the hook URL of webhook jobs
+	WebhookSecret     *string           `db:"webhook_secret" json:"-"`                                            // HMAC key signing hook requests, only returned on creation
+	PlanFailurePolicy PlanFailurePolicy `gorm:"not null;default:best_effort" db:"plan_failure_policy" json:"plan_failure_policy"`
+	CreatedAt         time.Time         `gorm:"not null" db:"created_at" json:"created_at"`
+	UpdatedAt         time.Time         `gorm:"not null" db:"updated_at" json:"updated_at"`
+	Version           int64             `gorm:"not null" db:"version" json:"version"`
+	RiverJobID        int64             `gorm:"not null" db:"river_job_id" json:"river_job_id"`
 }
 
 type Tasks struct {
-	ID        uuid.UUID  `gorm:"primaryKey" db:"id" json:"id"`
-	JobID     uuid.UUID  `gorm:"not null" db:"job_id" json:"job_id"`
-	Status    TaskStatus `gorm:"not null;default:created" db:"status" json:"status"`
-	Payload   string     `gorm:"not null" db:"payload" json:"payload"`
-	Result    string     `db:"result" json:"result"`
-	IsDeleted bool       `gorm:"not null;default:false" db:"is_deleted" json:"is_deleted"`
-	CreatedAt time.Time  `gorm:"not null" db:"created_at" json:"created_at"`
-	UpdatedAt time.Time  `gorm:"not null" db:"updated_at" json:"updated_at"`
-	Version   int64      `gorm:"not null" db:"version" json:"version"`
+	ID             uuid.UUID       `gorm:"primaryKey" db:"id" json:"id"`
+	JobID          uuid.UUID       `gorm:"not null" db:"job_id" json:"job_id"`
+	Status         TaskStatus      `gorm:"not null;default:created" db:"status" json:"status"`
+	Trigger        TaskTrigger     `gorm:"not null;default:schedule" db:"trigger" json:"trigger"`
+	UpstreamTaskID *uuid.UUID      `db:"upstream_task_id" json:"upstream_task_id,omitempty"`                 // Task whose outcome triggered a dependency run
+	RetryOfTaskID  *uuid.UUID      `db:"retry_of_task_id" json:"retry_of_task_id,omitempty"`                 // Task whose payload a retry run executes
+	ScheduledFor   *time.Time      `db:"scheduled_for" json:"scheduled_for,omitempty"`                       // Occurrence a scheduled or misfire run stands for
+	Request        *WebhookRequest `gorm:"serializer:json;type:jsonb" db:"request" json:"request,omitempty"` // Inbound request of a webhook run
+	RiverJobID     int64           `gorm:"not null;default:0" db:"river_job_id" json:"river_job_id"`
+	Attempt        int             `gorm:"not null;default:1" db:"attempt" json:"attempt"`
+	Payload        string          `gorm:"not null" db:"payload" json:"payload"`
+	Prompt         string          `db:"prompt" json:"prompt"` // Prompt rendered for the run
+	Result         string          `db:"result" json:"result"`
+	AgentURL       string          `db:"agent_url" json:"agent_url,omitempty"`         // A2A endpoint running the task, for cancellation
+	AgentTaskID    string          `db:"agent_task_id" json:"agent_task_id,omitempty"` // A2A task ID of the current attempt
+	IsDeleted      bool            `gorm:"not null;default:false" db:"is_deleted" json:"is_deleted"`
+	CreatedAt      time.Time       `gorm:"not null" db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time       `gorm:"not null" db:"updated_at" json:"updated_at"`
+	Version        int64           `gorm:"not null" db:"version" json:"version"`
+}
+
+// TaskAttempts records every execution attempt of a task
+type TaskAttempts struct {
+	ID         uuid.UUID  `gorm:"primaryKey" db:"id" json:"id"`
+	TaskID     uuid.UUID  `gorm:"not null;index" db:"task_id" json:"task_id"`
+	Attempt    int        `gorm:"not null" db:"attempt" json:"attempt"`
+	Status     TaskStatus `gorm:"not null" db:"status" json:"status"`
+	Error      string     `db:"error" json:"error,omitempty"`
+	ErrorClass ErrorClass `db:"error_class" json:"error_class,omitempty"`
+	StartedAt  time.Time  `gorm:"not null" db:"started_at" json:"started_at"`
+	FinishedAt *time.Time `db:"finished_at" json:"finished_at,omitempty"`
+	CreatedAt  time.Time  `gorm:"not null" db:"created_at" json:"created_at"`
+	UpdatedAt  time.Time  `gorm:"not null" db:"updated_at" json:"updated_at"`
+}
+
+// TaskSteps records the execution of one step of an agent plan run by a task
+type TaskSteps struct {
+	ID           uuid.UUID  `gorm:"primaryKey" db:"id" json:"id"`
+	TaskID       uuid.UUID  `gorm:"not null;index" db:"task_id" json:"task_id"`
+	Step         int        `gorm:"not null" db:"step" json:"step"`
+	PlanTaskID   string     `gorm:"not null" db:"plan_task_id" json:"plan_task_id"` // task_id of the step in the plan
+	Dependencies []string   `gorm:"serializer:json;type:jsonb" db:"dependencies" json:"dependencies"`
+	AgentName    string     `db:"agent_name" json:"agent_name"`
+	AgentAddress string     `db:"agent_address" json:"agent_address"`
+	AgentTaskID  string     `db:"agent_task_id" json:"agent_task_id,omitempty"` // A2A task ID of the step's current run
+	Task         string     `db:"task" json:"task"`                             // Task of the step as planned
+	Status       TaskStatus `gorm:"not null" db:"status" json:"status"`
+	Attempt      int        `gorm:"not null;default:1" db:"attempt" json:"attempt"` // Attempt of the task that last ran the step
+	Input        string     `db:"input" json:"input"`                               // Message sent, the task with its dependency results
+	Output       string     `db:"output" json:"output"`
+	Error        string     `db:"error" json:"error,omitempty"`
+	StartedAt    *time.Time `db:"started_at" json:"started_at,omitempty"`
+	FinishedAt   *time.Time `db:"finished_at" json:"finished_at,omitempty"`
+	CreatedAt    time.Time  `gorm:"not null" db:"created_at" json:"created_at"`
+	UpdatedAt    time.Time  `gorm:"not null" db:"updated_at" json:"updated_at"`
 }
 
 // Create Job Request DTO
 type CreateJobRequest struct {
-	Name        string    `json:"name" binding:"required,min=1,max=100"`
-	WorkspaceID uuid.UUID `json:"workspace_id" binding:"required"`
-	Payload     string    `json:"payload" binding:"required,max=20000"`
-	Type        JobType   `json:"type" binding:"required,oneof=scheduled interval"`
-	Schedule    *string   `json:"schedule,omitempty"`
-	Interval    *string   `json:"interval,omitempty"`
+	Name              string            `json:"name" binding:"required,min=1,max=100"`
+	WorkspaceID       uuid.UUID         `json:"workspace_id" binding:"required"`
+	Payload           string            `json:"payload" binding:"required,max=20000"`
+	Type              JobType           `json:"type" binding:"required,oneof=scheduled interval dependent webhook"`
+	Schedule          *string           `json:"schedule,omitempty"`
+	Interval          *string           `json:"interval,omitempty"`
+	OverlapPolicy     OverlapPolicy     `json:"overlap_policy,omitempty"`      // Optional, defaults to allow
+	RetryPolicy       *string           `json:"retry_policy,omitempty"`        // Optional RetryPolicy JSON, defaults to a single attempt
+	Timezone          string            `json:"timezone,omitempty"`            // Optional IANA timezone, defaults to UTC
+	StartsAt          *string           `json:"starts_at,omitempty"`           // Optional start of the run window
+	EndsAt            *string           `json:"ends_at,omitempty"`             // Optional end of the run window
+	MaxRuns           *int              `json:"max_runs,omitempty"`            // Optional number of scheduled runs
+	MisfirePolicy     MisfirePolicy     `json:"misfire_policy,omitempty"`      // Optional, defaults to skip
+	MisfireLimit      int               `json:"misfire_limit,omitempty"`       // Optional cap of run_all_missed
+	CalendarIDs       []uuid.UUID       `json:"calendar_ids,omitempty"`        // Optional calendars of the job's workspace
+	JitterSeconds     *int              `json:"jitter_seconds,omitempty"`      // Optional, defaults to the workspace spread
+	DependsOn         []JobDependency   `json:"depends_on,omitempty"`          // Optional upstream jobs, required for dependent jobs
+	PlanFailurePolicy PlanFailurePolicy `json:"plan_failure_policy,omitempty"` // Optional, defaults to best_effort
+}
+
+// Update Job Request DTO, omitted fields are left unchanged
+type UpdateJobRequest struct {
+	Name              *string            `json:"name,omitempty" binding:"omitempty,min=1,max=100"`
+	Payload           *string            `json:"payload,omitempty" binding:"omitempty,max=20000"`
+	Schedule          *string            `json:"schedule,omitempty"`
+	Interval          *string            `json:"interval,omitempty"`
+	OverlapPolicy     *OverlapPolicy     `json:"overlap_policy,omitempty"`
+	RetryPolicy       *string            `json:"retry_policy,omitempty"`
+	Timezone          *string            `json:"timezone,omitempty"`
+	StartsAt          *string            `json:"starts_at,omitempty"` // Empty string removes the start
+	EndsAt            *string            `json:"ends_at,omitempty"`   // Empty string removes the end
+	MaxRuns           *int               `json:"max_runs,omitempty"`  // 0 removes the limit
+	MisfirePolicy     *MisfirePolicy     `json:"misfire_policy,omitempty"`
+	MisfireLimit      *int               `json:"misfire_limit,omitempty"`  // 0 restores the default
+	CalendarIDs       *[]uuid.UUID       `json:"calendar_ids,omitempty"`   // Empty list removes all calendars
+	JitterSeconds     *int               `json:"jitter_seconds,omitempty"` // -1 restores the workspace spread
+	DependsOn         *[]JobDependency   `json:"depends_on,omitempty"`     // Empty list removes all dependencies
+	PlanFailurePolicy *PlanFailurePolicy `json:"plan_failure_policy,omitempty"`
 }
 
 // Create Job Response DTO
 type CreateJobResponse struct {
 	JobID uuid.UUID `json:"job_id"`
 }
+
+// Created Job Response DTO, the job with the webhook secret that other responses leave out
+type CreatedJobResponse struct {
+	*Jobs
+	WebhookSecret *string `json:"webhook_secret,omitempty"`
+}
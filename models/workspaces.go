@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WorkspaceSettings holds the scheduling settings of a user's jobs in a workspace. Workspaces have
+// no membership of their own, so settings are kept per user.
+type WorkspaceSettings struct {
+	WorkspaceID   uuid.UUID `gorm:"primaryKey" db:"workspace_id" json:"workspace_id"`
+	UserID        uuid.UUID `gorm:"primaryKey" db:"user_id" json:"user_id"`
+	SpreadSeconds int       `gorm:"not null;default:0" db:"spread_seconds" json:"spread_seconds"` // Jitter of jobs without their own, 0 disables spreading
+	CreatedAt     time.Time `gorm:"not null" db:"created_at" json:"created_at"`
+	UpdatedAt     time.Time `gorm:"not null" db:"updated_at" json:"updated_at"`
+}
+
+// Update Workspace Settings Request DTO
+type UpdateWorkspaceSettingsRequest struct {
+	SpreadSeconds int `json:"spread_seconds"`
+}
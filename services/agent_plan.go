@@ -0,0 +1,403 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gin-gorm-river-app/models"
+	"gin-gorm-river-app/shared"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// DefaultPlanConcurrency is the number of plan steps run at the same time when the
+// client agent data sets no max_parallel_steps
+const DefaultPlanConcurrency = 4
+
+var (
+	// ErrInvalidAgentPlan is returned for plans with duplicate or unknown task IDs, or cycles
+	ErrInvalidAgentPlan = errors.New("invalid agent plan")
+	// ErrAgentPlanFailed is returned when fewer steps than the min_success policy requires succeeded
+	ErrAgentPlanFailed = errors.New("agent plan failed")
+)
+
+// planFailurePolicy is a parsed models.PlanFailurePolicy
+type planFailurePolicy struct {
+	failFast   bool
+	minSuccess int // successful steps required for the task to succeed
+}
+
+// parsePlanFailurePolicy parses fail_fast, best_effort or min_success=N. Empty means best_effort.
+func parsePlanFailurePolicy(policy models.PlanFailurePolicy) (planFailurePolicy, error) {
+	switch policy {
+	case "", models.PlanFailurePolicyBestEffort:
+		return planFailurePolicy{minSuccess: 1}, nil
+	case models.PlanFailurePolicyFailFast:
+		return planFailurePolicy{failFast: true}, nil
+	}
+	if n, ok := strings.CutPrefix(string(policy), string(models.PlanFailurePolicyMinSuccess)); ok {
+		minSuccess, err := strconv.Atoi(n)
+		if err != nil || minSuccess < 1 {
+			return planFailurePolicy{}, fmt.Errorf("min_success must be a positive number of steps, got %q", n)
+		}
+		return planFailurePolicy{minSuccess: minSuccess}, nil
+	}
+	return planFailurePolicy{}, fmt.Errorf("plan_failure_policy must be one of fail_fast, best_effort, min_success=N")
+}
+
+// validateAgentPlan checks that task IDs are unique, that dependencies reference steps of
+// the plan and that they have no cycle
+func validateAgentPlan(plan []shared.IAgentTask) error {
+	steps := map[string]shared.IAgentTask{}
+	for _, step := range plan {
+		if _, ok := steps[step.TaskID]; ok {
+			return fmt.Errorf("%w: task %q appears twice", ErrInvalidAgentPlan, step.TaskID)
+		}
+		steps[step.TaskID] = step
+	}
+
+	remaining := map[string]int{}
+	dependents := map[string][]string{}
+	for _, step := range plan {
+		for _, dep := range step.Dependencies {
+			if _, ok := steps[dep]; !ok {
+				return fmt.Errorf("%w: task %q depends on unknown task %q", ErrInvalidAgentPlan, step.TaskID, dep)
+			}
+			dependents[dep] = append(dependents[dep], step.TaskID)
+		}
+		remaining[step.TaskID] = len(step.Dependencies)
+	}
+
+	// Kahn's algorithm: steps never freed are on a cycle or after one
+	queue := []string{}
+	for id, count := range remaining {
+		if count == 0 {
+			queue = append(queue, id)
+		}
+	}
+	visited := 0
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		visited++
+		for _, dependent := range dependents[id] {
+			remaining[dependent]--
+			if remaining[dependent] == 0 {
+				queue = append(queue, dependent)
+			}
+		}
+	}
+	if visited < len(plan) {
+		cyclic := []string{}
+		for id, count := range remaining {
+			if count > 0 {
+				cyclic = append(cyclic, id)
+			}
+		}
+		sort.Strings(cyclic)
+		return fmt.Errorf("%w: dependency cycle between tasks %v", ErrInvalidAgentPlan, cyclic)
+	}
+	return nil
+}
+
+// planStepOutcome is what a finished step reports to the plan scheduler
+type planStepOutcome struct {
+	step    shared.IAgentTask
+	content interface{}
+	err     error
+}
+
+// agentPlanOutcome is what a plan that ran to its end produced
+type agentPlanOutcome struct {
+	results []map[string]interface{} // results of the successful steps, ordered by step number
+	failed  int                      // steps that failed or were skipped
+}
+
+// runAgentPlan executes the steps of a plan in dependency order, running independent steps
+// concurrently up to maxParallel. A step starts once all its dependencies succeeded and receives
+// their results; a step whose dependency failed is skipped. Under a fail_fast policy the first
+// failed step cancels the steps still running and returns its error. Steps in completed already
+// ran, with the given output, and are not executed again.
+func runAgentPlan(ctx context.Context, plan []shared.IAgentTask, maxParallel int, policy planFailurePolicy, completed map[string]interface{}, recorder *planStepRecorder) (*agentPlanOutcome, error) {
+	if err := validateAgentPlan(plan); err != nil {
+		return nil, err
+	}
+	if maxParallel <= 0 {
+		maxParallel = DefaultPlanConcurrency
+	}
+	recorder.begin(plan)
+
+	// planCtx is canceled on the first failure of a fail_fast plan
+	planCtx, cancelPlan := context.WithCancel(ctx)
+	defer cancelPlan()
+	var failFastErr error
+
+	sort.SliceStable(plan, func(i, j int) bool { return plan[i].Step < plan[j].Step })
+	results := map[string]map[string]interface{}{}
+	blocked := map[string]bool{} // a dependency failed or was skipped
+	settled := map[string]bool{} // completed earlier, started or skipped
+	for _, step := range plan {
+		if content, ok := completed[step.TaskID]; ok {
+			results[step.TaskID] = planStepResult(step, content)
+			settled[step.TaskID] = true
+		}
+	}
+
+	steps := map[string]shared.IAgentTask{}
+	remaining := map[string]int{}
+	dependents := map[string][]string{}
+	for _, step := range plan {
+		steps[step.TaskID] = step
+		for _, dep := range step.Dependencies {
+			if settled[dep] {
+				continue
+			}
+			remaining[step.TaskID]++
+			dependents[dep] = append(dependents[dep], step.TaskID)
+		}
+	}
+
+	ready := []shared.IAgentTask{}
+	for _, step := range plan {
+		if !settled[step.TaskID] && remaining[step.TaskID] == 0 {
+			ready = append(ready, step)
+		}
+	}
+
+	done := make(chan planStepOutcome)
+	running := 0
+
+	// finish releases the dependents of a step that succeeded, failed or was skipped
+	var finish func(id string, succeeded bool)
+	finish = func(id string, succeeded bool) {
+		for _, dependentID := range dependents[id] {
+			if !succeeded {
+				blocked[dependentID] = true
+			}
+			remaining[dependentID]--
+			if remaining[dependentID] > 0 {
+				continue
+			}
+			if blocked[dependentID] {
+				log.Printf("Skipping plan task %s: a dependency did not complete", dependentID)
+				recorder.finish(dependentID, models.TaskStatusSkipped, nil, errors.New("a dependency did not complete"))
+				settled[dependentID] = true
+				finish(dependentID, false)
+				continue
+			}
+			ready = append(ready, steps[dependentID])
+			sort.SliceStable(ready, func(i, j int) bool { return ready[i].Step < ready[j].Step })
+		}
+	}
+
+	for running > 0 || (len(ready) > 0 && planCtx.Err() == nil) {
+		for len(ready) > 0 && running < maxParallel && planCtx.Err() == nil {
+			step := ready[0]
+			ready = ready[1:]
+			running++
+			input := planStepInput(step, results)
+			agentTaskID := uuid.New().String()
+			recorder.start(step.TaskID, input, agentTaskID)
+			settled[step.TaskID] = true
+			go func(step shared.IAgentTask, input string) {
+				content, err := executeAIAgent(planCtx, step.AgentAddress+"/messages", agentTaskID, input, nil)
+				done <- planStepOutcome{step: step, content: content, err: err}
+			}(step, input)
+		}
+
+		outcome := <-done
+		running--
+		id := outcome.step.TaskID
+		if outcome.err != nil {
+			log.Printf("Plan task %s (step %d) failed: %v", id, outcome.step.Step, outcome.err)
+			recorder.finish(id, stepFailedStatus(outcome.err), nil, outcome.err)
+			if policy.failFast && failFastErr == nil && ctx.Err() == nil {
+				failFastErr = fmt.Errorf("plan task %s (step %d) failed: %w", id, outcome.step.Step, outcome.err)
+				cancelPlan()
+			}
+			finish(id, false)
+			continue
+		}
+		recorder.finish(id, models.TaskStatusCompleted, outcome.content, nil)
+		results[id] = planStepResult(outcome.step, outcome.content)
+		log.Printf("Completed plan task %s (step %d)", id, outcome.step.Step)
+		finish(id, true)
+	}
+
+	// A deadline hit mid-plan must not be reported as a (partial) success
+	planErr := ctx.Err()
+	if planErr == nil {
+		planErr = failFastErr
+	}
+	if planErr != nil {
+		for _, step := range plan {
+			if !settled[step.TaskID] {
+				recorder.finish(step.TaskID, models.TaskStatusCanceled, nil, planErr)
+			}
+		}
+		return nil, planErr
+	}
+
+	outcome := &agentPlanOutcome{results: []map[string]interface{}{}}
+	for _, step := range plan {
+		if result, ok := results[step.TaskID]; ok {
+			outcome.results = append(outcome.results, result)
+		} else {
+			outcome.failed++
+		}
+	}
+	return outcome, nil
+}
+
+// planStepResult is the result of a successful step, as passed to its dependents and returned
+func planStepResult(step shared.IAgentTask, content interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"agentName": step.AgentName,
+		"taskId":    step.TaskID,
+		"content":   content,
+	}
+}
+
+// result is the result of a task that ran a plan: the results of its successful steps, partial
+// when some steps failed, or an error when fewer steps than the policy requires succeeded
+func (policy planFailurePolicy) result(outcome *agentPlanOutcome, err error) (interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+	succeeded := len(outcome.results)
+	if succeeded == 0 {
+		return nil, fmt.Errorf("no final result found")
+	}
+	if succeeded < policy.minSuccess {
+		return nil, fmt.Errorf("%w: %d of %d steps succeeded, %d required", ErrAgentPlanFailed, succeeded, succeeded+outcome.failed, policy.minSuccess)
+	}
+	if outcome.failed > 0 {
+		return PartialResult{Result: outcome.results}, nil
+	}
+	return outcome.results, nil
+}
+
+// resumeAgentPlan runs again the plan recorded in the task_steps of a task. Completed steps keep
+// their output, which is passed to their dependents; every other step is executed again.
+func resumeAgentPlan(ctx context.Context, jobArgs shared.ProcessJobArgs, maxParallel int, policy planFailurePolicy, tasksService *TasksService) (*agentPlanOutcome, error) {
+	recorded, err := tasksService.GetTaskSteps(jobArgs.TaskID)
+	if err != nil {
+		return nil, err
+	}
+	if len(recorded) == 0 {
+		return nil, fmt.Errorf("%w: task %s has no recorded plan", ErrInvalidAgentPlan, jobArgs.TaskID)
+	}
+
+	plan := make([]shared.IAgentTask, 0, len(recorded))
+	completed := map[string]interface{}{}
+	recorder := newPlanStepRecorder(tasksService, jobArgs.TaskID, jobArgs.Attempt)
+	recorder.stepIDs = map[string]uuid.UUID{}
+	for _, step := range recorded {
+		plan = append(plan, shared.IAgentTask{
+			Step:         step.Step,
+			AgentName:    step.AgentName,
+			AgentAddress: step.AgentAddress,
+			TaskID:       step.PlanTaskID,
+			Task:         step.Task,
+			Dependencies: step.Dependencies,
+		})
+		recorder.stepIDs[step.PlanTaskID] = step.ID
+		if step.Status == models.TaskStatusCompleted {
+			completed[step.PlanTaskID] = step.Output
+		}
+	}
+	log.Printf("Resuming plan of task %s: %d of %d steps already completed", jobArgs.TaskID, len(completed), len(plan))
+	return runAgentPlan(ctx, plan, maxParallel, policy, completed, recorder)
+}
+
+// planStepInput is the message of a step: its task followed by the results of its dependencies
+func planStepInput(step shared.IAgentTask, results map[string]map[string]interface{}) string {
+	var prevResults []map[string]interface{}
+	for _, dep := range step.Dependencies {
+		if result, ok := results[dep]; ok {
+			prevResults = append(prevResults, result)
+		}
+	}
+	if len(prevResults) == 0 {
+		return step.Task
+	}
+	prevResultsJSON, _ := json.Marshal(prevResults)
+	return step.Task + fmt.Sprintf("\nPrevious results: %s", string(prevResultsJSON))
+}
+
+// stepFailedStatus maps the error of a step to its status
+func stepFailedStatus(err error) models.TaskStatus {
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return models.TaskStatusTimedOut
+	case errors.Is(err, context.Canceled):
+		return models.TaskStatusCanceled
+	}
+	return models.TaskStatusFailed
+}
+
+// planStepRecorder persists the progress of a plan as the task_steps of its task.
+// A nil recorder records nothing; failures to record are logged and do not stop the plan.
+type planStepRecorder struct {
+	tasksService *TasksService
+	taskID       uuid.UUID
+	attempt      int
+	stepIDs      map[string]uuid.UUID
+}
+
+func newPlanStepRecorder(tasksService *TasksService, taskID uuid.UUID, attempt int) *planStepRecorder {
+	return &planStepRecorder{tasksService: tasksService, taskID: taskID, attempt: attempt}
+}
+
+// begin records the steps of a new plan. A resumed plan keeps the steps already recorded.
+func (r *planStepRecorder) begin(plan []shared.IAgentTask) {
+	if r == nil || r.stepIDs != nil {
+		return
+	}
+	stepIDs, err := r.tasksService.CreateTaskSteps(r.taskID, r.attempt, plan)
+	if err != nil {
+		log.Printf("Failed to record plan of task %s: %v", r.taskID, err)
+		return
+	}
+	r.stepIDs = stepIDs
+}
+
+func (r *planStepRecorder) start(planTaskID string, input string, agentTaskID string) {
+	if r == nil {
+		return
+	}
+	if stepID, ok := r.stepIDs[planTaskID]; ok {
+		if err := r.tasksService.StartTaskStep(stepID, r.attempt, input, agentTaskID); err != nil {
+			log.Printf("Failed to record start of plan task %s of task %s: %v", planTaskID, r.taskID, err)
+		}
+	}
+}
+
+func (r *planStepRecorder) finish(planTaskID string, status models.TaskStatus, content interface{}, stepErr error) {
+	if r == nil {
+		return
+	}
+	stepID, ok := r.stepIDs[planTaskID]
+	if !ok {
+		return
+	}
+	output := ""
+	if str, ok := content.(string); ok {
+		output = str
+	} else if content != nil {
+		outputJSON, _ := json.Marshal(content)
+		output = string(outputJSON)
+	}
+	errMessage := ""
+	if stepErr != nil {
+		errMessage = stepErr.Error()
+	}
+	if err := r.tasksService.FinishTaskStep(stepID, status, output, errMessage); err != nil {
+		log.Printf("Failed to record outcome of plan task %s of task %s: %v", planTaskID, r.taskID, err)
+	}
+}
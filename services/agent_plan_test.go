@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gin-gorm-river-app/shared"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// stubAgent is an A2A agent answering each plan step with "result of <task id>". The step is
+// read from the agent address, <server>/<task id>. Steps in fail answer with an error and steps
+// in block only return once their request is canceled.
+type stubAgent struct {
+	server   *httptest.Server
+	fail     map[string]bool
+	block    map[string]bool
+	mu       sync.Mutex
+	messages map[string]string // message received by each step that ran
+}
+
+func newStubAgent(t *testing.T) *stubAgent {
+	t.Helper()
+	agent := &stubAgent{fail: map[string]bool{}, block: map[string]bool{}, messages: map[string]string{}}
+	agent.server = httptest.NewServer(http.HandlerFunc(agent.serveHTTP))
+	t.Cleanup(agent.server.Close)
+	return agent
+}
+
+func (a *stubAgent) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	var request shared.SendTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.Method == "tasks/cancel" {
+		json.NewEncoder(w).Encode(shared.SendTaskResponse{JSONRPC: "2.0", ID: request.ID, Result: &shared.Task{ID: request.Params.ID, Status: shared.TaskStatus{State: "canceled"}}})
+		return
+	}
+
+	taskID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/"), "/messages")
+	a.mu.Lock()
+	a.messages[taskID] = request.Params.Message.Parts[0].Text
+	a.mu.Unlock()
+
+	switch {
+	case a.fail[taskID]:
+		http.Error(w, "agent failure", http.StatusInternalServerError)
+		return
+	case a.block[taskID]:
+		<-r.Context().Done()
+		return
+	}
+	reply := &shared.Message{Role: "agent", Parts: []shared.TextPart{{Type: "text", Text: "result of " + taskID}}}
+	json.NewEncoder(w).Encode(shared.SendTaskResponse{JSONRPC: "2.0", ID: request.ID, Result: &shared.Task{ID: request.Params.ID, Status: shared.TaskStatus{State: "completed", Message: reply}}})
+}
+
+// step returns a plan step run by the stub agent
+func (a *stubAgent) step(number int, taskID string, dependencies ...string) shared.IAgentTask {
+	return shared.IAgentTask{
+		Step:         number,
+		AgentName:    "stub",
+		AgentAddress: a.server.URL + "/" + taskID,
+		TaskID:       taskID,
+		Task:         "do " + taskID,
+		Dependencies: dependencies,
+	}
+}
+
+func (a *stubAgent) message(taskID string) (string, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	message, ok := a.messages[taskID]
+	return message, ok
+}
+
+func resultTaskIDs(outcome *agentPlanOutcome) []string {
+	ids := []string{}
+	for _, result := range outcome.results {
+		ids = append(ids, result["taskId"].(string))
+	}
+	return ids
+}
+
+func TestValidateAgentPlan(t *testing.T) {
+	step := func(taskID string, dependencies ...string) shared.IAgentTask {
+		return shared.IAgentTask{TaskID: taskID, Dependencies: dependencies}
+	}
+
+	tests := []struct {
+		name    string
+		plan    []shared.IAgentTask
+		wantErr string
+	}{
+		{"independent steps", []shared.IAgentTask{step("a"), step("b")}, ""},
+		{"diamond", []shared.IAgentTask{step("a"), step("b", "a"), step("c", "a"), step("d", "b", "c")}, ""},
+		{"duplicate task", []shared.IAgentTask{step("a"), step("a")}, `task "a" appears twice`},
+		{"unknown dependency", []shared.IAgentTask{step("a"), step("b", "z")}, `task "b" depends on unknown task "z"`},
+		{"self dependency", []shared.IAgentTask{step("a", "a")}, "dependency cycle between tasks [a]"},
+		{"cycle", []shared.IAgentTask{step("a"), step("b", "a", "d"), step("c", "b"), step("d", "c")}, "dependency cycle between tasks [b c d]"},
+		{"after a cycle", []shared.IAgentTask{step("a", "b"), step("b", "a"), step("c", "a")}, "dependency cycle between tasks [a b c]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAgentPlan(tt.plan)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateAgentPlan: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidAgentPlan) || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("validateAgentPlan error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunAgentPlanRejectsInvalidPlan(t *testing.T) {
+	agent := newStubAgent(t)
+	plan := []shared.IAgentTask{agent.step(1, "a", "b"), agent.step(2, "b", "a")}
+
+	_, err := runAgentPlan(context.Background(), plan, 0, planFailurePolicy{minSuccess: 1}, nil, nil)
+	if !errors.Is(err, ErrInvalidAgentPlan) {
+		t.Fatalf("runAgentPlan error = %v, want ErrInvalidAgentPlan", err)
+	}
+	if len(agent.messages) != 0 {
+		t.Errorf("agent received %v, want no step run", agent.messages)
+	}
+}
+
+func TestRunAgentPlanDiamond(t *testing.T) {
+	agent := newStubAgent(t)
+	plan := []shared.IAgentTask{agent.step(4, "d", "b", "c"), agent.step(2, "b", "a"), agent.step(3, "c", "a"), agent.step(1, "a")}
+
+	outcome, err := runAgentPlan(context.Background(), plan, 2, planFailurePolicy{minSuccess: 1}, nil, nil)
+	if err != nil {
+		t.Fatalf("runAgentPlan: %v", err)
+	}
+	if got := strings.Join(resultTaskIDs(outcome), ","); got != "a,b,c,d" || outcome.failed != 0 {
+		t.Fatalf("results = %s with %d failed, want a,b,c,d with none failed", got, outcome.failed)
+	}
+
+	if message, _ := agent.message("a"); message != "do a" {
+		t.Errorf("message of a = %q, want the task alone", message)
+	}
+	message, _ := agent.message("d")
+	if !strings.HasPrefix(message, "do d\nPrevious results: ") || !strings.Contains(message, "result of b") || !strings.Contains(message, "result of c") {
+		t.Errorf("message of d = %q, want the results of b and c", message)
+	}
+}
+
+func TestRunAgentPlanSkipsDependentsOfFailedSteps(t *testing.T) {
+	agent := newStubAgent(t)
+	agent.fail["a"] = true
+	plan := []shared.IAgentTask{agent.step(1, "a"), agent.step(2, "b", "a"), agent.step(3, "c", "b"), agent.step(4, "d")}
+
+	outcome, err := runAgentPlan(context.Background(), plan, 1, planFailurePolicy{minSuccess: 1}, nil, nil)
+	if err != nil {
+		t.Fatalf("runAgentPlan: %v", err)
+	}
+	if got := strings.Join(resultTaskIDs(outcome), ","); got != "d" || outcome.failed != 3 {
+		t.Fatalf("results = %s with %d failed, want d with 3 failed", got, outcome.failed)
+	}
+	for _, skipped := range []string{"b", "c"} {
+		if _, ran := agent.message(skipped); ran {
+			t.Errorf("step %s ran after its dependency failed", skipped)
+		}
+	}
+}
+
+func TestRunAgentPlanFailFast(t *testing.T) {
+	agent := newStubAgent(t)
+	agent.fail["a"] = true
+	agent.block["b"] = true
+	plan := []shared.IAgentTask{agent.step(1, "a"), agent.step(2, "b"), agent.step(3, "c", "b"), agent.step(4, "d")}
+
+	// a fails while b is running, d waits for a free slot
+	_, err := runAgentPlan(context.Background(), plan, 2, planFailurePolicy{failFast: true}, nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "plan task a (step 1) failed") {
+		t.Fatalf("runAgentPlan error = %v, want the failure of a", err)
+	}
+	var statusErr *shared.HTTPStatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("runAgentPlan error = %v, want it to wrap the agent error", err)
+	}
+	for _, canceled := range []string{"c", "d"} {
+		if _, ran := agent.message(canceled); ran {
+			t.Errorf("step %s started after the plan failed", canceled)
+		}
+	}
+}
+
+func TestRunAgentPlanResume(t *testing.T) {
+	agent := newStubAgent(t)
+	plan := []shared.IAgentTask{agent.step(1, "a"), agent.step(2, "b", "a"), agent.step(3, "c", "b")}
+	completed := map[string]interface{}{"a": "earlier result of a"}
+
+	outcome, err := runAgentPlan(context.Background(), plan, 0, planFailurePolicy{minSuccess: 1}, completed, nil)
+	if err != nil {
+		t.Fatalf("runAgentPlan: %v", err)
+	}
+	if got := strings.Join(resultTaskIDs(outcome), ","); got != "a,b,c" || outcome.failed != 0 {
+		t.Fatalf("results = %s with %d failed, want a,b,c with none failed", got, outcome.failed)
+	}
+	if _, ran := agent.message("a"); ran {
+		t.Error("completed step a ran again")
+	}
+	if message, _ := agent.message("b"); !strings.Contains(message, "earlier result of a") {
+		t.Errorf("message of b = %q, want the earlier result of a", message)
+	}
+	if content := outcome.results[0]["content"]; content != "earlier result of a" {
+		t.Errorf("result of a = %v, want the earlier result", content)
+	}
+}
@@ -0,0 +1,272 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gin-gorm-river-app/config"
+	"gin-gorm-river-app/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrCalendarNotFound is returned when a calendar does not exist or does not belong to the caller
+var ErrCalendarNotFound = errors.New("calendar not found or access denied")
+
+type CalendarService struct {
+	db *config.Database
+}
+
+func NewCalendarService(db *config.Database) *CalendarService {
+	return &CalendarService{
+		db: db,
+	}
+}
+
+// CreateCalendar
+func (s *CalendarService) CreateCalendar(ctx context.Context, req *models.CalendarRequest, userId uuid.UUID) (*models.Calendars, error) {
+	if err := validateCalendarRequest(req); err != nil {
+		return nil, err
+	}
+
+	calendar := &models.Calendars{
+		ID:          uuid.New(),
+		UserID:      userId,
+		WorkspaceID: req.WorkspaceID,
+		CreatedAt:   time.Now(),
+	}
+	applyCalendarRequest(calendar, req)
+
+	if err := s.db.GORM.WithContext(ctx).Create(calendar).Error; err != nil {
+		return nil, err
+	}
+	return calendar, nil
+}
+
+// GetCalendars lists the calendars of a workspace
+func (s *CalendarService) GetCalendars(ctx context.Context, userId uuid.UUID, workspaceId uuid.UUID) ([]models.Calendars, error) {
+	calendars := []models.Calendars{}
+	result := s.db.GORM.WithContext(ctx).
+		Where("user_id = ? AND workspace_id = ? AND is_deleted = false", userId, workspaceId).
+		Order("name ASC").
+		Find(&calendars)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to fetch calendars: %w", result.Error)
+	}
+	return calendars, nil
+}
+
+// GetCalendar returns a non-deleted calendar owned by the user
+func (s *CalendarService) GetCalendar(ctx context.Context, id uuid.UUID, userId uuid.UUID) (*models.Calendars, error) {
+	calendar := &models.Calendars{}
+	if err := s.db.GORM.WithContext(ctx).Where("id = ? AND user_id = ? AND is_deleted = false", id, userId).First(calendar).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCalendarNotFound
+		}
+		return nil, err
+	}
+	return calendar, nil
+}
+
+// UpdateCalendar replaces the dates and ranges of a calendar. Runs already queued in River are
+// checked again by the worker when they start.
+func (s *CalendarService) UpdateCalendar(ctx context.Context, id uuid.UUID, userId uuid.UUID, req *models.CalendarRequest) (*models.Calendars, error) {
+	calendar, err := s.GetCalendar(ctx, id, userId)
+	if err != nil {
+		return nil, err
+	}
+	if req.WorkspaceID != calendar.WorkspaceID {
+		return nil, &ValidationError{Errors: []FieldError{{Field: "workspace_id", Message: "a calendar cannot move to another workspace"}}}
+	}
+	if err := validateCalendarRequest(req); err != nil {
+		return nil, err
+	}
+
+	applyCalendarRequest(calendar, req)
+	if err := s.db.GORM.WithContext(ctx).Save(calendar).Error; err != nil {
+		return nil, err
+	}
+	return calendar, nil
+}
+
+// DeleteCalendar soft deletes a calendar, jobs referencing it stop applying its blackouts
+func (s *CalendarService) DeleteCalendar(ctx context.Context, id uuid.UUID, userId uuid.UUID) error {
+	result := s.db.GORM.WithContext(ctx).Model(&models.Calendars{}).
+		Where("id = ? AND user_id = ? AND is_deleted = false", id, userId).
+		Updates(map[string]interface{}{
+			"is_deleted": true,
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrCalendarNotFound
+	}
+	return nil
+}
+
+func applyCalendarRequest(calendar *models.Calendars, req *models.CalendarRequest) {
+	calendar.Name = req.Name
+	calendar.Timezone = req.Timezone
+	if calendar.Timezone == "" {
+		calendar.Timezone = DefaultJobTimezone
+	}
+	calendar.ExcludedDates = req.ExcludedDates
+	calendar.BlackoutRanges = req.BlackoutRanges
+	calendar.UpdatedAt = time.Now()
+}
+
+// validateCalendarRequest reports every invalid field of a calendar request as a *ValidationError
+func validateCalendarRequest(req *models.CalendarRequest) error {
+	verr := &ValidationError{}
+
+	if _, err := loadTimezone(req.Timezone); err != nil {
+		verr.add("timezone", "%v", err)
+	}
+	for i, date := range req.ExcludedDates {
+		if err := validateExcludedDate(date); err != nil {
+			verr.add(fmt.Sprintf("excluded_dates[%d]", i), "%v", err)
+		}
+	}
+	for i, blackout := range req.BlackoutRanges {
+		field := fmt.Sprintf("blackout_ranges[%d]", i)
+		for _, day := range blackout.Weekdays {
+			if _, ok := weekdays[strings.ToLower(day)]; !ok {
+				verr.add(field+".weekdays", "unknown weekday %q, use mon, tue, wed, thu, fri, sat or sun", day)
+			}
+		}
+		start, err := parseClock(blackout.Start, false)
+		if err != nil {
+			verr.add(field+".start", "%v", err)
+		}
+		end, err := parseClock(blackout.End, true)
+		if err != nil {
+			verr.add(field+".end", "%v", err)
+		} else if end == start {
+			verr.add(field+".end", "end must differ from start")
+		}
+	}
+
+	if len(verr.Errors) > 0 {
+		return verr
+	}
+	return nil
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// validateExcludedDate accepts YYYY-MM-DD, or MM-DD which repeats every year
+func validateExcludedDate(value string) error {
+	if _, err := time.Parse("2006-01-02", value); err == nil {
+		return nil
+	}
+	if _, err := time.Parse("01-02", value); err == nil {
+		return nil
+	}
+	return fmt.Errorf("invalid date %q, use YYYY-MM-DD or MM-DD", value)
+}
+
+// parseClock parses HH:MM into minutes after midnight. 24:00 is only accepted as an end.
+func parseClock(value string, isEnd bool) (int, error) {
+	if isEnd && value == "24:00" {
+		return 24 * 60, nil
+	}
+	hours, minutes, ok := strings.Cut(value, ":")
+	h, errH := strconv.Atoi(hours)
+	m, errM := strconv.Atoi(minutes)
+	if !ok || len(minutes) != 2 || errH != nil || errM != nil || h < 0 || h > 23 || m < 0 || m > 59 {
+		return 0, fmt.Errorf("invalid time %q, use HH:MM", value)
+	}
+	return h*60 + m, nil
+}
+
+// jobCalendars are the calendars referenced by a job
+type jobCalendars []models.Calendars
+
+// loadJobCalendars returns the calendars of a job that still exist in its workspace and belong to its user
+func loadJobCalendars(db *config.Database, job *models.Jobs) (jobCalendars, error) {
+	if len(job.CalendarIDs) == 0 {
+		return nil, nil
+	}
+	var calendars []models.Calendars
+	result := db.GORM.Where("id IN ? AND workspace_id = ? AND user_id = ? AND is_deleted = false", job.CalendarIDs, job.WorkspaceID, job.UserID).
+		Find(&calendars)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to fetch calendars of job %s: %w", job.ID, result.Error)
+	}
+	return calendars, nil
+}
+
+// suppression returns why a run at t is blacked out by one of the calendars, or "" when it is not.
+// Dates and ranges are read on the wall clock of each calendar's timezone.
+func (c jobCalendars) suppression(t time.Time) string {
+	for _, calendar := range c {
+		loc, err := loadTimezone(calendar.Timezone)
+		if err != nil {
+			loc = time.UTC
+		}
+		wall := t.In(loc)
+
+		date, yearly := wall.Format("2006-01-02"), wall.Format("01-02")
+		for _, excluded := range calendar.ExcludedDates {
+			if excluded == date || excluded == yearly {
+				return fmt.Sprintf("calendar %q excludes %s", calendar.Name, excluded)
+			}
+		}
+
+		minute := wall.Hour()*60 + wall.Minute()
+		for _, blackout := range calendar.BlackoutRanges {
+			if blackoutCovers(blackout, wall.Weekday(), minute) {
+				days := "every day"
+				if len(blackout.Weekdays) > 0 {
+					days = strings.Join(blackout.Weekdays, ", ")
+				}
+				return fmt.Sprintf("calendar %q blacks out %s-%s on %s", calendar.Name, blackout.Start, blackout.End, days)
+			}
+		}
+	}
+	return ""
+}
+
+// blackoutCovers reports whether minute of a day with the given weekday is inside the range.
+// A range ending before it starts covers the end of its days and the start of the next ones.
+func blackoutCovers(blackout models.BlackoutRange, day time.Weekday, minute int) bool {
+	start, err := parseClock(blackout.Start, false)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(blackout.End, true)
+	if err != nil {
+		return false
+	}
+
+	onDay := func(d time.Weekday) bool {
+		if len(blackout.Weekdays) == 0 {
+			return true
+		}
+		for _, name := range blackout.Weekdays {
+			if weekdays[strings.ToLower(name)] == d {
+				return true
+			}
+		}
+		return false
+	}
+
+	if start < end {
+		return onDay(day) && minute >= start && minute < end
+	}
+	return (onDay(day) && minute >= start) || (onDay((day+6)%7) && minute < end)
+}
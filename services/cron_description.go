@@ -0,0 +1,230 @@
+package services
+
+import (
+	"fmt"
+	"gin-gorm-river-app/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "Every year on January 1 at 00:00",
+	"@annually": "Every year on January 1 at 00:00",
+	"@monthly":  "Every month on day 1 at 00:00",
+	"@weekly":   "Every Sunday at 00:00",
+	"@daily":    "Every day at 00:00",
+	"@midnight": "Every day at 00:00",
+	"@hourly":   "Every hour",
+}
+
+var weekdayNames = []string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"}
+
+var monthNames = []string{"", "January", "February", "March", "April", "May", "June",
+	"July", "August", "September", "October", "November", "December"}
+
+// describeJobSchedule returns a human-readable description of a job's schedule
+func describeJobSchedule(job *models.Jobs) string {
+	loc, err := loadTimezone(job.Timezone)
+	if err != nil {
+		return ""
+	}
+
+	switch job.Type {
+	case models.JobTypeScheduled:
+		if job.NextRunAt == nil {
+			return ""
+		}
+		return "Once at " + job.NextRunAt.In(loc).Format(time.RFC3339)
+	case models.JobTypeInterval:
+		schedule, err := jobIntervalSchedule(job)
+		if err != nil {
+			return ""
+		}
+		if fixed, ok := schedule.(*fixedIntervalSchedule); ok {
+			return fixed.describe() + " (" + loc.String() + ")"
+		}
+		return describeCron(intervalValue(job)) + " (" + loc.String() + ")"
+	case models.JobTypeDependent:
+		if len(job.DependsOn) == 1 {
+			return "After job " + job.DependsOn[0].JobID.String() + " (" + string(job.DependsOn[0].Condition) + ")"
+		}
+		return fmt.Sprintf("After any of %d upstream jobs", len(job.DependsOn))
+	case models.JobTypeWebhook:
+		return "When its hook URL is called"
+	}
+	return ""
+}
+
+// intervalValue returns the raw value of an interval job
+func intervalValue(job *models.Jobs) string {
+	data, err := parseIntervalData(job.Interval)
+	if err != nil || data.Value == nil {
+		return ""
+	}
+	return *data.Value
+}
+
+func (s *fixedIntervalSchedule) describe() string {
+	anchor := s.anchor.In(s.loc)
+	unit := strings.TrimSuffix(string(s.unit), "s")
+	every := "Every " + unit
+	if s.every > 1 {
+		every = fmt.Sprintf("Every %d %s", s.every, s.unit)
+	}
+
+	switch s.unit {
+	case models.IntervalTypeDays:
+		description := every + " at " + anchor.Format("15:04")
+		if s.every > 1 {
+			description += " starting on " + anchor.Format("2006-01-02")
+		}
+		return description
+	case models.IntervalTypeMonths:
+		description := fmt.Sprintf("%s on day %d at %s", every, anchor.Day(), anchor.Format("15:04"))
+		if s.every > 1 {
+			description += " starting in " + anchor.Format("January 2006")
+		}
+		if anchor.Day() > 28 {
+			description += ", or the last day of shorter months"
+		}
+		return description
+	default:
+		return every + " starting at " + anchor.Format(time.RFC3339)
+	}
+}
+
+// describeCron returns a human-readable description of a standard 5-field cron expression.
+// Fields it cannot phrase are quoted as they are.
+func describeCron(expr string) string {
+	expr = strings.TrimSpace(expr)
+	if description, ok := cronDescriptors[expr]; ok {
+		return description
+	}
+	if every, ok := strings.CutPrefix(expr, "@every "); ok {
+		return "Every " + strings.TrimSpace(every)
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return expr
+	}
+	minute, hour, dayOfMonth, month, dayOfWeek := fields[0], fields[1], fields[2], fields[3], fields[4]
+
+	parts := []string{describeCronTime(minute, hour)}
+
+	var days []string
+	if !isCronWildcard(dayOfMonth) {
+		days = append(days, "on day "+describeCronList(dayOfMonth, nil)+" of the month")
+	}
+	if !isCronWildcard(dayOfWeek) {
+		days = append(days, "on "+describeCronList(dayOfWeek, weekdayNames))
+	}
+	if len(days) > 0 {
+		// cron matches either day field when both are restricted
+		parts = append(parts, strings.Join(days, " or "))
+	}
+	if !isCronWildcard(month) {
+		parts = append(parts, "in "+describeCronList(month, monthNames))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func describeCronTime(minute string, hour string) string {
+	minuteValue, minuteFixed := cronNumber(minute)
+	hourValue, hourFixed := cronNumber(hour)
+
+	switch {
+	case minuteFixed && hourFixed:
+		return fmt.Sprintf("At %02d:%02d", hourValue, minuteValue)
+	case minuteFixed && isCronHourList(hour):
+		times := []string{}
+		for _, h := range strings.Split(hour, ",") {
+			value, _ := cronNumber(h)
+			times = append(times, fmt.Sprintf("%02d:%02d", value, minuteValue))
+		}
+		return "At " + joinWords(times)
+	case minuteFixed && hour == "*":
+		return fmt.Sprintf("At minute %d of every hour", minuteValue)
+	case minuteFixed && strings.HasPrefix(hour, "*/"):
+		return fmt.Sprintf("At minute %d of every %s hours", minuteValue, strings.TrimPrefix(hour, "*/"))
+	}
+
+	var every string
+	switch {
+	case minute == "*":
+		every = "Every minute"
+	case strings.HasPrefix(minute, "*/"):
+		every = "Every " + strings.TrimPrefix(minute, "*/") + " minutes"
+	default:
+		return fmt.Sprintf("At minute %s past hour %s", minute, hour)
+	}
+
+	switch {
+	case hour == "*":
+		return every
+	case hourFixed:
+		return fmt.Sprintf("%s between %02d:00 and %02d:59", every, hourValue, hourValue)
+	}
+	if from, to, ok := strings.Cut(hour, "-"); ok {
+		fromValue, fromOK := cronNumber(from)
+		toValue, toOK := cronNumber(to)
+		if fromOK && toOK {
+			return fmt.Sprintf("%s between %02d:00 and %02d:59", every, fromValue, toValue)
+		}
+	}
+	return every + " during hour " + hour
+}
+
+// describeCronList phrases a list of values and ranges, using names when given
+func describeCronList(field string, names []string) string {
+	items := []string{}
+	for _, item := range strings.Split(field, ",") {
+		if from, to, ok := strings.Cut(item, "-"); ok && !strings.Contains(item, "/") {
+			items = append(items, cronName(from, names)+" through "+cronName(to, names))
+			continue
+		}
+		items = append(items, cronName(item, names))
+	}
+	return joinWords(items)
+}
+
+func cronName(value string, names []string) string {
+	n, ok := cronNumber(value)
+	if !ok || names == nil {
+		return value
+	}
+	if names[0] == "Sunday" && n == 7 {
+		n = 0
+	}
+	if n < 0 || n >= len(names) || names[n] == "" {
+		return value
+	}
+	return names[n]
+}
+
+func cronNumber(value string) (int, bool) {
+	n, err := strconv.Atoi(value)
+	return n, err == nil
+}
+
+func isCronWildcard(field string) bool {
+	return field == "*" || field == "?"
+}
+
+func isCronHourList(field string) bool {
+	for _, item := range strings.Split(field, ",") {
+		if _, ok := cronNumber(item); !ok {
+			return false
+		}
+	}
+	return strings.Contains(field, ",")
+}
+
+// joinWords joins items as "a, b and c"
+func joinWords(items []string) string {
+	if len(items) <= 1 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " and " + items[len(items)-1]
+}
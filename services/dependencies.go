@@ -0,0 +1,169 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gin-gorm-river-app/models"
+	"log"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// validateJobDependencies checks the conditions of depends_on and that every upstream job
+// exists in the job's workspace and belongs to the same user, whose results it will read
+func (s *JobService) validateJobDependencies(workspaceID uuid.UUID, userId uuid.UUID, dependsOn []models.JobDependency) error {
+	if len(dependsOn) == 0 {
+		return nil
+	}
+
+	seen := map[uuid.UUID]bool{}
+	for _, dependency := range dependsOn {
+		switch dependency.Condition {
+		case models.DependencyOnSuccess, models.DependencyOnFailure, models.DependencyAlways:
+		default:
+			return fmt.Errorf("condition of %s must be one of on_success, on_failure, always", dependency.JobID)
+		}
+		if seen[dependency.JobID] {
+			return fmt.Errorf("job %s is listed more than once", dependency.JobID)
+		}
+		seen[dependency.JobID] = true
+	}
+
+	var count int64
+	result := s.db.GORM.Model(&models.Jobs{}).
+		Where("id IN ? AND workspace_id = ? AND user_id = ? AND is_deleted = false", mapKeys(seen), workspaceID, userId).
+		Count(&count)
+	if result.Error != nil {
+		return fmt.Errorf("failed to check upstream jobs: %w", result.Error)
+	}
+	if int(count) != len(seen) {
+		return fmt.Errorf("depends_on must reference your jobs of the same workspace")
+	}
+	return nil
+}
+
+// rowQuerier is a pgx pool or transaction
+type rowQuerier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// lockUserDependencies serializes, until tx ends, the changes to the dependencies of a user's jobs.
+// Upstream jobs always belong to the same user, so two updates closing a cycle cannot both pass.
+func lockUserDependencies(ctx context.Context, tx pgx.Tx, userId uuid.UUID) error {
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1, 0))`, "job_dependencies:"+userId.String()); err != nil {
+		return fmt.Errorf("failed to lock dependencies of user %s: %w", userId, err)
+	}
+	return nil
+}
+
+// checkDependencyCycle walks the upstream jobs of jobID and reports a cycle leading back to it.
+// Updates run it on their transaction after lockUserDependencies.
+func (s *JobService) checkDependencyCycle(ctx context.Context, q rowQuerier, jobID uuid.UUID, dependsOn []models.JobDependency) error {
+	// parent records the downstream job each upstream job was reached from
+	parent := map[uuid.UUID]uuid.UUID{}
+	queue := []uuid.UUID{}
+	for _, dependency := range dependsOn {
+		if _, ok := parent[dependency.JobID]; !ok {
+			parent[dependency.JobID] = jobID
+			queue = append(queue, dependency.JobID)
+		}
+	}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == jobID {
+			return &ValidationError{Err: ErrInvalidJobRequest, Errors: []FieldError{{Field: "depends_on", Message: "dependency cycle: " + cyclePath(jobID, parent)}}}
+		}
+
+		var upstreamDependsOn []models.JobDependency
+		err := q.QueryRow(ctx, `SELECT depends_on FROM jobs WHERE id = $1 AND is_deleted = false`, current).Scan(&upstreamDependsOn)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			return fmt.Errorf("failed to load upstream job %s: %w", current, err)
+		}
+		for _, dependency := range upstreamDependsOn {
+			if _, ok := parent[dependency.JobID]; !ok {
+				parent[dependency.JobID] = current
+				queue = append(queue, dependency.JobID)
+			}
+		}
+	}
+	return nil
+}
+
+// cyclePath formats the cycle found by checkDependencyCycle, from jobID to its upstream jobs and back
+func cyclePath(jobID uuid.UUID, parent map[uuid.UUID]uuid.UUID) string {
+	path := []string{jobID.String()}
+	for current := parent[jobID]; current != jobID; current = parent[current] {
+		path = append(path, current.String())
+	}
+	path = append(path, jobID.String())
+	return strings.Join(path, " -> ")
+}
+
+// GetDependentJobs returns the active jobs of the upstream job's owner that depend on it
+func (s *JobService) GetDependentJobs(ctx context.Context, upstreamID uuid.UUID) ([]models.Jobs, error) {
+	filter, err := json.Marshal([]map[string]string{{"job_id": upstreamID.String()}})
+	if err != nil {
+		return nil, err
+	}
+
+	var jobs []models.Jobs
+	result := s.db.GORM.WithContext(ctx).
+		Where("status = 'active' AND is_deleted = false AND depends_on @> ?::jsonb AND user_id = (SELECT user_id FROM jobs WHERE id = ?)", string(filter), upstreamID).
+		Find(&jobs)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to fetch dependent jobs of %s: %w", upstreamID, result.Error)
+	}
+	return jobs, nil
+}
+
+// TriggerDependentJobs enqueues a run of every job whose dependency on the upstream job
+// matches the outcome of its finished task
+func (s *JobService) TriggerDependentJobs(ctx context.Context, upstreamID uuid.UUID, taskID uuid.UUID, status models.TaskStatus) {
+	dependents, err := s.GetDependentJobs(ctx, upstreamID)
+	if err != nil {
+		log.Printf("Failed to trigger dependents of job %s: %v", upstreamID, err)
+		return
+	}
+
+	riverClient := GetRiverClientInstance(s.db)
+	for i := range dependents {
+		dependent := &dependents[i]
+		for _, dependency := range dependent.DependsOn {
+			if dependency.JobID != upstreamID || !dependencyMatches(dependency.Condition, status) {
+				continue
+			}
+			if _, err := riverClient.EnqueueDependentRun(ctx, dependent, taskID); err != nil {
+				log.Printf("Failed to trigger job %s after task %s: %v", dependent.ID, taskID, err)
+			} else {
+				log.Printf("Triggered job %s after task %s of job %s (%s)", dependent.ID, taskID, upstreamID, status)
+			}
+		}
+	}
+}
+
+// dependencyMatches reports whether a finished task with the given status satisfies condition
+func dependencyMatches(condition models.DependencyCondition, status models.TaskStatus) bool {
+	switch condition {
+	case models.DependencyAlways:
+		return true
+	case models.DependencyOnSuccess:
+		return status == models.TaskStatusCompleted || status == models.TaskStatusPartiallyCompleted
+	case models.DependencyOnFailure:
+		return status == models.TaskStatusFailed || status == models.TaskStatusTimedOut || status == models.TaskStatusCanceled
+	}
+	return false
+}
+
+func mapKeys(m map[uuid.UUID]bool) []uuid.UUID {
+	keys := make([]uuid.UUID, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+}
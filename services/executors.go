@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gin-gorm-river-app/models"
+	"gin-gorm-river-app/shared"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// ResourceExecutor runs the jobs of one resource type. New resource types register an executor
+// with RegisterResourceExecutor, the worker and job validation pick it up by its ResourceName.
+type ResourceExecutor interface {
+	// ValidateResourceData checks the resource_data of a payload when a job is created or updated
+	ValidateResourceData(resourceData string) error
+	// Execute runs a task of the job and returns its result. ctx carries the run deadline.
+	Execute(ctx context.Context, jobArgs shared.ProcessJobArgs, tasksService *TasksService) (interface{}, error)
+}
+
+// ResourceDataRenderer is implemented by executors whose resource_data holds prompt templates.
+// render is called on each template: it checks the template when a job is validated and renders it
+// with the variables of the run in the worker. escape, when set, encodes each value for the
+// template's format.
+type ResourceDataRenderer interface {
+	RenderResourceData(resourceData string, render func(template string, escape func(value string) string) (string, error)) (string, error)
+}
+
+// PartialResult is returned by an executor whose run succeeded only in part, such as an agent plan
+// with failed steps. The task stores Result and ends partially_completed.
+type PartialResult struct {
+	Result interface{}
+}
+
+var (
+	executorsMu       sync.RWMutex
+	resourceExecutors = map[models.ResourceName]ResourceExecutor{}
+)
+
+// RegisterResourceExecutor makes an executor available for a resource type. It panics when
+// the resource type already has one.
+func RegisterResourceExecutor(name models.ResourceName, executor ResourceExecutor) {
+	executorsMu.Lock()
+	defer executorsMu.Unlock()
+	if _, ok := resourceExecutors[name]; ok {
+		panic(fmt.Sprintf("resource executor %s registered twice", name))
+	}
+	resourceExecutors[name] = executor
+}
+
+// GetResourceExecutor returns the executor of a resource type
+func GetResourceExecutor(name models.ResourceName) (ResourceExecutor, bool) {
+	executorsMu.RLock()
+	defer executorsMu.RUnlock()
+	executor, ok := resourceExecutors[name]
+	return executor, ok
+}
+
+// resourceNames lists the registered resource types, for error messages
+func resourceNames() string {
+	executorsMu.RLock()
+	defer executorsMu.RUnlock()
+	names := make([]string, 0, len(resourceExecutors))
+	for name := range resourceExecutors {
+		names = append(names, string(name))
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+var resourceDataValidator = validator.New()
+
+// decodeResourceData unmarshals resource_data into data and checks its `validate` tags
+func decodeResourceData(resourceData string, data interface{}) error {
+	if err := json.Unmarshal([]byte(resourceData), data); err != nil {
+		return fmt.Errorf("invalid resource_data: %v", err)
+	}
+	if err := resourceDataValidator.Struct(data); err != nil {
+		return fmt.Errorf("invalid resource_data: %v", err)
+	}
+	return nil
+}
+
+func init() {
+	RegisterResourceExecutor(models.AIAgent, aiAgentExecutor{})
+	RegisterResourceExecutor(models.ClientAgent, clientAgentExecutor{})
+}
+
+// aiAgentExecutor sends the prompt to an A2A agent and streams its output into the task
+type aiAgentExecutor struct{}
+
+func (aiAgentExecutor) ValidateResourceData(resourceData string) error {
+	return decodeResourceData(resourceData, &models.AIAgentData{})
+}
+
+func (aiAgentExecutor) Execute(ctx context.Context, jobArgs shared.ProcessJobArgs, tasksService *TasksService) (interface{}, error) {
+	return processAIAgentJob(ctx, jobArgs, tasksService)
+}
+
+// clientAgentExecutor sends the prompt to a client agent and runs the plan it may reply with
+type clientAgentExecutor struct{}
+
+func (clientAgentExecutor) ValidateResourceData(resourceData string) error {
+	return decodeResourceData(resourceData, &models.ClientAgentData{})
+}
+
+func (clientAgentExecutor) Execute(ctx context.Context, jobArgs shared.ProcessJobArgs, tasksService *TasksService) (interface{}, error) {
+	return processClientAgentJob(ctx, jobArgs, tasksService)
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gin-gorm-river-app/models"
+	"gin-gorm-river-app/shared"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// maxHTTPResponseBody caps the response body stored in the task result
+const maxHTTPResponseBody = 1 << 20
+
+func init() {
+	RegisterResourceExecutor(models.HTTPRequest, httpRequestExecutor{})
+}
+
+// httpRequestExecutor calls an HTTP API and stores its response as the task result
+type httpRequestExecutor struct{}
+
+func (httpRequestExecutor) ValidateResourceData(resourceData string) error {
+	data := &models.HTTPRequestData{}
+	if err := decodeResourceData(resourceData, data); err != nil {
+		return err
+	}
+	for name, path := range data.Extract {
+		if _, err := parseJSONPath(path); err != nil {
+			return fmt.Errorf("extract.%s: %v", name, err)
+		}
+	}
+	target, err := url.Parse(data.URL)
+	if err != nil {
+		return fmt.Errorf("url: %v", err)
+	}
+	if err := loadHTTPRequestPolicy().checkURL(target); err != nil {
+		return fmt.Errorf("url: %v", err)
+	}
+	return nil
+}
+
+// RenderResourceData renders the body template of the request. Values rendered in a JSON body are
+// escaped as JSON string contents so they cannot break out of the string they are placed in.
+func (httpRequestExecutor) RenderResourceData(resourceData string, render func(template string, escape func(value string) string) (string, error)) (string, error) {
+	data := &models.HTTPRequestData{}
+	if err := json.Unmarshal([]byte(resourceData), data); err != nil {
+		return "", err
+	}
+	var escape func(string) string
+	if isJSONBody(data) {
+		escape = escapeJSONString
+	}
+	body, err := render(data.Body, escape)
+	if err != nil {
+		return "", fmt.Errorf("body: %v", err)
+	}
+	data.Body = body
+	rendered, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+	return string(rendered), nil
+}
+
+// isJSONBody reports whether the request body is JSON, by its Content-Type header or, without one,
+// by its first character
+func isJSONBody(data *models.HTTPRequestData) bool {
+	for name, value := range data.Headers {
+		if strings.EqualFold(name, "Content-Type") {
+			return strings.Contains(strings.ToLower(value), "json")
+		}
+	}
+	body := strings.TrimSpace(data.Body)
+	return strings.HasPrefix(body, "{") || strings.HasPrefix(body, "[")
+}
+
+func (httpRequestExecutor) Execute(ctx context.Context, jobArgs shared.ProcessJobArgs, tasksService *TasksService) (interface{}, error) {
+	payload := models.Payload{}
+	if err := json.Unmarshal([]byte(jobArgs.Payload), &payload); err != nil {
+		return nil, err
+	}
+	data := models.HTTPRequestData{}
+	if err := json.Unmarshal([]byte(payload.ResourceData), &data); err != nil {
+		return nil, err
+	}
+
+	if err := tasksService.UpdateTaskById(jobArgs.TaskID, models.TaskStatusRunning); err != nil {
+		log.Printf("Failed to update task status to running: %v", err)
+		return nil, err
+	}
+
+	method := data.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if data.Body != "" {
+		body = strings.NewReader(data.Body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, data.URL, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range data.Headers {
+		req.Header.Set(name, value)
+	}
+	if err := loadHTTPRequestPolicy().checkURL(req.URL); err != nil {
+		return nil, err
+	}
+
+	resp, err := httpRequestClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPResponseBody))
+	if err != nil {
+		return nil, err
+	}
+
+	result := models.HTTPRequestResult{
+		StatusCode: resp.StatusCode,
+		Headers:    storedResponseHeaders(resp.Header),
+		Body:       string(respBody),
+	}
+
+	expected := resp.StatusCode >= 200 && resp.StatusCode < 300
+	if len(data.ExpectedStatus) > 0 {
+		expected = slices.Contains(data.ExpectedStatus, resp.StatusCode)
+	}
+	if !expected {
+		// The failed task keeps the response, the error only drives the retry policy
+		if resultJSON, err := json.Marshal(result); err != nil {
+			log.Printf("Failed to marshal response of task %s: %v", jobArgs.TaskID, err)
+		} else if err := tasksService.SetTaskResult(jobArgs.TaskID, string(resultJSON)); err != nil {
+			log.Printf("Failed to store response of task %s: %v", jobArgs.TaskID, err)
+		}
+		return nil, &shared.HTTPStatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
+	}
+
+	if len(data.Extract) > 0 {
+		var doc interface{}
+		if err := json.Unmarshal(respBody, &doc); err != nil {
+			return nil, fmt.Errorf("extract: response body is not JSON: %v", err)
+		}
+		result.Extracted = map[string]interface{}{}
+		for name, path := range data.Extract {
+			value, err := evalJSONPath(doc, path)
+			if err != nil {
+				return nil, err
+			}
+			result.Extracted[name] = value
+		}
+	}
+	return result, nil
+}
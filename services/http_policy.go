@@ -0,0 +1,133 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// defaultDeniedNetworks are refused to http_request jobs unless HTTP_REQUEST_DENIED_NETWORKS is set.
+// Link-local addresses serve cloud metadata endpoints such as 169.254.169.254.
+const defaultDeniedNetworks = "169.254.0.0/16,fe80::/10"
+
+// sensitiveResponseHeaders are dropped from the response stored in the task result
+var sensitiveResponseHeaders = []string{"Set-Cookie", "Set-Cookie2", "Authorization", "Proxy-Authorization"}
+
+// errHTTPRequestDenied is returned when an http_request job targets a host or address the policy refuses
+var errHTTPRequestDenied = errors.New("http_request target is not allowed")
+
+// httpRequestPolicy restricts the targets of http_request jobs. It is read from the environment:
+//   - HTTP_REQUEST_ALLOWED_HOSTS: comma-separated host names, "*.example.com" matching subdomains;
+//     any host when empty
+//   - HTTP_REQUEST_DENIED_NETWORKS: comma-separated CIDRs refused whatever the host resolves to;
+//     link-local networks when unset
+type httpRequestPolicy struct {
+	allowedHosts   []string
+	deniedNetworks []netip.Prefix
+}
+
+var loadHTTPRequestPolicy = sync.OnceValue(func() *httpRequestPolicy {
+	policy := &httpRequestPolicy{}
+	for _, host := range strings.Split(os.Getenv("HTTP_REQUEST_ALLOWED_HOSTS"), ",") {
+		if host = strings.ToLower(strings.TrimSpace(host)); host != "" {
+			policy.allowedHosts = append(policy.allowedHosts, host)
+		}
+	}
+
+	denied, ok := os.LookupEnv("HTTP_REQUEST_DENIED_NETWORKS")
+	if !ok {
+		denied = defaultDeniedNetworks
+	}
+	for _, cidr := range strings.Split(denied, ",") {
+		if cidr = strings.TrimSpace(cidr); cidr == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			log.Printf("Ignoring invalid network %q in HTTP_REQUEST_DENIED_NETWORKS: %v", cidr, err)
+			continue
+		}
+		policy.deniedNetworks = append(policy.deniedNetworks, prefix.Masked())
+	}
+	return policy
+})
+
+// checkURL refuses a URL whose host is not allowed, or which is a denied IP address
+func (p *httpRequestPolicy) checkURL(target *url.URL) error {
+	host := strings.ToLower(target.Hostname())
+	if ip, err := netip.ParseAddr(host); err == nil {
+		if err := p.checkAddr(ip); err != nil {
+			return err
+		}
+	}
+	if len(p.allowedHosts) == 0 {
+		return nil
+	}
+	for _, allowed := range p.allowedHosts {
+		if host == allowed {
+			return nil
+		}
+		if suffix, ok := strings.CutPrefix(allowed, "*"); ok && strings.HasSuffix(host, suffix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: host %s is not in HTTP_REQUEST_ALLOWED_HOSTS", errHTTPRequestDenied, host)
+}
+
+// checkAddr refuses an address of a denied network
+func (p *httpRequestPolicy) checkAddr(ip netip.Addr) error {
+	ip = ip.Unmap()
+	for _, network := range p.deniedNetworks {
+		if network.Contains(ip) {
+			return fmt.Errorf("%w: %s is in denied network %s", errHTTPRequestDenied, ip, network)
+		}
+	}
+	return nil
+}
+
+// httpRequestClient is the client of http_request jobs. Addresses are checked when dialed, so a
+// host resolving to a denied network is refused too, and redirects are checked like the request.
+var httpRequestClient = sync.OnceValue(func() *http.Client {
+	policy := loadHTTPRequestPolicy()
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			return policy.checkAddr(addrPort.Addr())
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return policy.checkURL(req.URL)
+		},
+	}
+})
+
+// storedResponseHeaders returns the response headers without the sensitive ones
+func storedResponseHeaders(header http.Header) http.Header {
+	stored := header.Clone()
+	for _, name := range sensitiveResponseHeaders {
+		stored.Del(name)
+	}
+	return stored
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"gin-gorm-river-app/config"
+	"gin-gorm-river-app/models"
+	"hash/fnv"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MaxJitter bounds the per-job jitter and the workspace spread
+const MaxJitter = time.Hour
+
+func validateJitterSeconds(field string, seconds int) error {
+	if seconds < 0 || time.Duration(seconds)*time.Second > MaxJitter {
+		return fmt.Errorf("%s must be between 0 and %d", field, int(MaxJitter.Seconds()))
+	}
+	return nil
+}
+
+// jitterOffset returns the delay of a job's runs within window. It only depends on the job ID
+// so a job keeps the same run time from one occurrence to the next.
+func jitterOffset(jobID uuid.UUID, window time.Duration) time.Duration {
+	if window < time.Second {
+		return 0
+	}
+	hash := fnv.New64a()
+	hash.Write(jobID[:])
+	return time.Duration(hash.Sum64()%uint64(window/time.Second+1)) * time.Second
+}
+
+// jobJitterOffset returns how long after its NextRunAt a job's run is scheduled in River.
+// The window is the job's jitter_seconds, or the spread its user set for the workspace when it has none.
+// For interval jobs it is capped at half the time to the following run so runs keep their order.
+func jobJitterOffset(db *config.Database, job *models.Jobs) (time.Duration, error) {
+	var window time.Duration
+	if job.JitterSeconds != nil {
+		window = time.Duration(*job.JitterSeconds) * time.Second
+	} else {
+		settings := &models.WorkspaceSettings{}
+		err := db.GORM.Where("workspace_id = ? AND user_id = ?", job.WorkspaceID, job.UserID).First(settings).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, fmt.Errorf("failed to fetch settings of workspace %s: %w", job.WorkspaceID, err)
+		}
+		window = time.Duration(settings.SpreadSeconds) * time.Second
+	}
+	window = min(window, MaxJitter)
+
+	if window > 0 && job.Type == models.JobTypeInterval && job.NextRunAt != nil {
+		if schedule, err := jobIntervalSchedule(job); err == nil {
+			if following := schedule.Next(*job.NextRunAt); !following.IsZero() {
+				window = min(window, following.Sub(*job.NextRunAt)/2)
+			}
+		}
+	}
+	return jitterOffset(job.ID, window), nil
+}
@@ -12,10 +12,25 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/robfig/cron/v3"
+	"github.com/jackc/pgx/v5"
 	"gorm.io/gorm"
 )
 
+var (
+	// ErrJobNotFound is returned when a job does not exist or does not belong to the caller
+	ErrJobNotFound = errors.New("job not found or access denied")
+	// ErrJobVersionConflict is returned when the job was modified since the caller read it
+	ErrJobVersionConflict = errors.New("job was modified by another request")
+	// ErrInvalidJobRequest wraps validation errors of job create/update requests
+	ErrInvalidJobRequest = errors.New("invalid job request")
+	// ErrJobNotActive is returned when an action requires an active job
+	ErrJobNotActive = errors.New("job is not active")
+	// ErrJobScheduleElapsed is returned when a job has no future run left to schedule
+	ErrJobScheduleElapsed = errors.New("job has no future run to schedule")
+	// ErrTaskNotFound is returned when a task does not exist or does not belong to the caller
+	ErrTaskNotFound = errors.New("task not found or access denied")
+)
+
 type JobService struct {
 	db *config.Database
 }
@@ -28,7 +43,7 @@ func NewJobService(db *config.Database) *JobService {
 
 // CreateJob
 func (s *JobService) CreateJob(ctx context.Context, req *models.CreateJobRequest, userId string) (*models.Jobs, error) {
-	if err := s.validateJobRequest(req); err != nil {
+	if err := s.validateJobRequest(req, uuid.MustParse(userId)); err != nil {
 		return nil, err
 	}
 
@@ -45,10 +60,50 @@ func (s *JobService) CreateJob(ctx context.Context, req *models.CreateJobRequest
 		CreatedAt:   time.Now(),
 		UpdatedAt:   time.Now(),
 		Version:     1,
+
+		OverlapPolicy: req.OverlapPolicy,
+		RetryPolicy:   req.RetryPolicy,
+		Timezone:      req.Timezone,
+		MaxRuns:       req.MaxRuns,
+		MisfirePolicy: req.MisfirePolicy,
+		MisfireLimit:  req.MisfireLimit,
+		CalendarIDs:   req.CalendarIDs,
+		JitterSeconds: req.JitterSeconds,
+		DependsOn:     req.DependsOn,
+
+		PlanFailurePolicy: req.PlanFailurePolicy,
+	}
+	if job.OverlapPolicy == "" {
+		job.OverlapPolicy = models.OverlapPolicyAllow
+	}
+	if job.PlanFailurePolicy == "" {
+		job.PlanFailurePolicy = models.PlanFailurePolicyBestEffort
+	}
+	if job.MisfirePolicy == "" {
+		job.MisfirePolicy = models.MisfirePolicySkip
+	}
+	if job.Timezone == "" {
+		job.Timezone = DefaultJobTimezone
+	}
+	if err := setRunWindow(job, req.StartsAt, req.EndsAt); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidJobRequest, err)
+	}
+	if job.Type == models.JobTypeWebhook {
+		token, secret, err := newWebhookCredentials()
+		if err != nil {
+			return nil, err
+		}
+		job.WebhookToken, job.WebhookSecret = &token, &secret
+	}
+	if len(job.DependsOn) > 0 {
+		// Nothing can depend on a job being created yet, so the walk needs no lock
+		if err := s.checkDependencyCycle(ctx, s.db.Pool, job.ID, job.DependsOn); err != nil {
+			return nil, err
+		}
 	}
 
 	if err := s.calculateNextRunTime(job); err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %v", ErrInvalidJobRequest, err)
 	}
 
 	// Begin transaction
@@ -69,18 +124,20 @@ func (s *JobService) CreateJob(ctx context.Context, req *models.CreateJobRequest
 		return nil, err
 	}
 
-	// Schedule job in River
-	if err := GetRiverClientInstance(s.db).ScheduleJobInRiver(ctx, job); err != nil {
-		tx.Rollback()
-		return nil, err
-	}
+	// Schedule job in River, dependent and webhook jobs only run when triggered
+	if job.NextRunAt != nil {
+		if err := GetRiverClientInstance(s.db).ScheduleJobInRiver(ctx, job); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
 
-	// Update job with River job ID
-	query := `UPDATE jobs SET river_job_id = $1 WHERE id = $2 AND status = 'active' AND is_deleted = false`
-	err := tx.Exec(query, job.RiverJobID, job.ID).Error
-	if err != nil {
-		tx.Rollback()
-		return nil, err
+		// Update job with River job ID
+		query := `UPDATE jobs SET river_job_id = $1 WHERE id = $2 AND status = 'active' AND is_deleted = false`
+		err := tx.Exec(query, job.RiverJobID, job.ID).Error
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
 	}
 
 	// Commit transaction
@@ -91,36 +148,180 @@ func (s *JobService) CreateJob(ctx context.Context, req *models.CreateJobRequest
 	return job, nil
 }
 
-func (s *JobService) validateJobRequest(req *models.CreateJobRequest) error {
+// validateJobRequest checks every field of a job request and reports all invalid ones
+// as a *ValidationError
+func (s *JobService) validateJobRequest(req *models.CreateJobRequest, userId uuid.UUID) error {
+	verr := &ValidationError{Err: ErrInvalidJobRequest}
+
+	var payload models.Payload
+	if err := json.Unmarshal([]byte(req.Payload), &payload); err != nil {
+		verr.add("payload", "invalid payload: %v", err)
+	} else {
+		if payload.TimeoutSeconds < 0 || time.Duration(payload.TimeoutSeconds)*time.Second > MaxJobTimeout {
+			verr.add("payload.timeout_seconds", "timeout_seconds must be between 0 and %d", int(MaxJobTimeout.Seconds()))
+		}
+		if err := validatePromptTemplate(payload.Prompt, req.Type, len(req.DependsOn) > 0); err != nil {
+			verr.add("payload.prompt", "%v", err)
+		}
+		if executor, ok := GetResourceExecutor(payload.ResourceName); !ok {
+			verr.add("payload.resource_name", "resource_name must be one of %s", resourceNames())
+		} else if err := executor.ValidateResourceData(payload.ResourceData); err != nil {
+			verr.add("payload.resource_data", "%v", err)
+		} else if renderer, ok := executor.(ResourceDataRenderer); ok {
+			_, err := renderer.RenderResourceData(payload.ResourceData, func(template string, _ func(string) string) (string, error) {
+				return template, validatePromptTemplate(template, req.Type, len(req.DependsOn) > 0)
+			})
+			if err != nil {
+				verr.add("payload.resource_data", "%v", err)
+			}
+		}
+	}
+
+	switch req.OverlapPolicy {
+	case "", models.OverlapPolicySkip, models.OverlapPolicyQueue, models.OverlapPolicyReplace, models.OverlapPolicyAllow:
+	default:
+		verr.add("overlap_policy", "overlap_policy must be one of skip, queue, replace, allow")
+	}
+
+	if _, err := parseRetryPolicy(req.RetryPolicy); err != nil {
+		verr.add("retry_policy", "%v", err)
+	}
+
+	if _, err := parsePlanFailurePolicy(req.PlanFailurePolicy); err != nil {
+		verr.add("plan_failure_policy", "%v", err)
+	}
+
+	if err := validateMisfirePolicy(req.MisfirePolicy, req.MisfireLimit); err != nil {
+		verr.add("misfire_policy", "%v", err)
+	}
+
+	loc, err := loadTimezone(req.Timezone)
+	if err != nil {
+		verr.add("timezone", "%v", err)
+		loc = time.UTC
+	}
+
+	startsAt, err := parseJobTime(req.StartsAt, loc)
+	if err != nil {
+		verr.add("starts_at", "%v", err)
+	}
+	endsAt, err := parseJobTime(req.EndsAt, loc)
+	if err != nil {
+		verr.add("ends_at", "%v", err)
+	}
+	if startsAt != nil && endsAt != nil && !endsAt.After(*startsAt) {
+		verr.add("ends_at", "ends_at must be after starts_at")
+	}
+	if req.MaxRuns != nil && *req.MaxRuns < 1 {
+		verr.add("max_runs", "max_runs must be at least 1")
+	}
+	window := &models.Jobs{StartsAt: startsAt, EndsAt: endsAt}
+
+	if err := s.validateJobCalendars(req.WorkspaceID, userId, req.CalendarIDs); err != nil {
+		verr.add("calendar_ids", "%v", err)
+	}
+
+	if req.JitterSeconds != nil {
+		if err := validateJitterSeconds("jitter_seconds", *req.JitterSeconds); err != nil {
+			verr.add("jitter_seconds", "%v", err)
+		}
+	}
+
+	if err := s.validateJobDependencies(req.WorkspaceID, userId, req.DependsOn); err != nil {
+		verr.add("depends_on", "%v", err)
+	}
+
 	switch req.Type {
+	case models.JobTypeDependent:
+		if len(req.DependsOn) == 0 {
+			verr.add("depends_on", "depends_on is required for dependent jobs")
+		}
+		if req.Schedule != nil || req.Interval != nil {
+			verr.add("type", "dependent jobs have no schedule or interval")
+		}
+	case models.JobTypeWebhook:
+		if req.Schedule != nil || req.Interval != nil {
+			verr.add("type", "webhook jobs have no schedule or interval")
+		}
 	case models.JobTypeScheduled:
 		if req.Schedule == nil {
-			return fmt.Errorf("schedule is required for interval jobs")
+			verr.add("schedule", "schedule is required for scheduled jobs")
+			break
 		}
 		var scheduleData models.ScheduleData
 		if err := json.Unmarshal([]byte(*req.Schedule), &scheduleData); err != nil {
-			return err
+			verr.add("schedule", "invalid schedule: %v", err)
+			break
 		}
 		if scheduleData.ExecuteAt == nil {
-			return fmt.Errorf("execute_at is required for scheduled jobs")
+			verr.add("schedule.execute_at", "execute_at is required for scheduled jobs")
+		} else if *scheduleData.ExecuteAt != "now" {
+			if executeAt, err := parseDateTime(*scheduleData.ExecuteAt, loc); err != nil {
+				verr.add("schedule.execute_at", "%v", err)
+			} else if !withinRunWindow(window, executeAt) {
+				verr.add("schedule.execute_at", "execute_at must be between starts_at and ends_at")
+			}
 		}
-		return nil
 	case models.JobTypeInterval:
 		if req.Interval == nil {
-			return fmt.Errorf("schedule is required for interval jobs")
+			verr.add("interval", "interval is required for interval jobs")
+			break
 		}
 		var intervalData models.IntervalData
 		if err := json.Unmarshal([]byte(*req.Interval), &intervalData); err != nil {
-			return err
+			verr.add("interval", "invalid interval: %v", err)
+			break
+		}
+		switch intervalData.IntervalType {
+		case "":
+			verr.add("interval.interval_type", "interval_type is required for interval jobs")
+		case models.IntervalTypeMinutes, models.IntervalTypeHours, models.IntervalTypeDays, models.IntervalTypeMonths, models.IntervalTypeCron:
+		default:
+			verr.add("interval.interval_type", "interval_type must be one of minutes, hours, days, months, cron")
 		}
-		if intervalData.IntervalType == "" {
-			return fmt.Errorf("interval_type is required for interval jobs")
+		if intervalData.StartAt != nil {
+			if _, err := parseDateTime(*intervalData.StartAt, loc); err != nil {
+				verr.add("interval.start_at", "%v", err)
+				intervalData.StartAt = nil
+			}
 		}
 		if intervalData.Value == nil {
-			return fmt.Errorf("value is required for interval jobs")
+			verr.add("interval.value", "value is required for interval jobs")
+		} else if schedule, err := newIntervalSchedule(intervalData, time.Now(), loc); err != nil {
+			verr.add("interval.value", "%v", err)
+		} else if intervalData.IntervalType == models.IntervalTypeCron {
+			if err := checkCronGap(schedule, time.Now()); err != nil {
+				verr.add("interval.value", "%v", err)
+			}
 		}
+	}
+
+	if len(verr.Errors) > 0 {
+		return verr
+	}
+	return nil
+}
+
+// validateJobCalendars checks that every calendar exists in the job's workspace and belongs to the user
+func (s *JobService) validateJobCalendars(workspaceID uuid.UUID, userId uuid.UUID, calendarIDs []uuid.UUID) error {
+	if len(calendarIDs) == 0 {
 		return nil
 	}
+	unique := map[uuid.UUID]bool{}
+	for _, id := range calendarIDs {
+		unique[id] = true
+	}
+
+	var count int64
+	result := s.db.GORM.Model(&models.Calendars{}).
+		Where("id IN ? AND workspace_id = ? AND user_id = ? AND is_deleted = false", calendarIDs, workspaceID, userId).
+		Count(&count)
+	if result.Error != nil {
+		return fmt.Errorf("failed to check calendars: %w", result.Error)
+	}
+	if int(count) != len(unique) {
+		return fmt.Errorf("calendar_ids must reference your calendars of the job's workspace")
+	}
 	return nil
 }
 
@@ -141,8 +342,13 @@ func (s *JobService) calculateNextRunTimeForScheduledJob(job *models.Jobs) error
 		return nil
 	}
 
+	loc, err := loadTimezone(job.Timezone)
+	if err != nil {
+		return err
+	}
+
 	// Parse with timezone support
-	parsedTime, err := s.parseDateTime(*scheduleData.ExecuteAt)
+	parsedTime, err := parseDateTime(*scheduleData.ExecuteAt, loc)
 	if err != nil {
 		return fmt.Errorf("failed to parse execute_at '%s': %w", *scheduleData.ExecuteAt, err)
 	}
@@ -152,42 +358,43 @@ func (s *JobService) calculateNextRunTimeForScheduledJob(job *models.Jobs) error
 		return fmt.Errorf("scheduled time cannot be in the past: %s", parsedTime.Format(time.RFC3339))
 	}
 
+	calendars, err := loadJobCalendars(s.db, job)
+	if err != nil {
+		return err
+	}
+	if reason := calendars.suppression(parsedTime); reason != "" {
+		return fmt.Errorf("execute_at %s is blacked out: %s", parsedTime.Format(time.RFC3339), reason)
+	}
+
 	job.NextRunAt = &parsedTime
 	log.Printf("Job %s scheduled for: %s", job.ID, parsedTime.Format(time.RFC3339))
 	return nil
 
 }
 
+// calculateNextRunTimeForIntervalJob sets the next run inside the job's window.
+// It returns ErrJobScheduleElapsed when the window is closed or max_runs are used.
+//
+// When the calendars black out every occurrence up to runSearchHorizon, the next run is the last
+// of them: the worker records it as skipped and searches again from there.
 func (s *JobService) calculateNextRunTimeForIntervalJob(job *models.Jobs) error {
-	var intervalData models.IntervalData
-	if err := json.Unmarshal([]byte(*job.Interval), &intervalData); err != nil {
+	calendars, err := loadJobCalendars(s.db, job)
+	if err != nil {
 		return err
 	}
-	if intervalData.Value == nil {
-		return fmt.Errorf("value is required for interval jobs")
-	}
-	schedule, err := cron.ParseStandard(*intervalData.Value)
+	runs, _, horizonReached, err := searchRunTimes(job, time.Now(), 1, calendars)
 	if err != nil {
 		return err
 	}
-	now := time.Now() // VPS timezone
-	nextRun := schedule.Next(now)
-	job.NextRunAt = &nextRun
-	return nil
-}
-
-func (s *JobService) parseDateTime(dateStr string) (time.Time, error) {
-	supportedFormats := []string{
-		"2006-01-02T15:04:05.000Z",
-	}
-
-	for _, format := range supportedFormats {
-		if t, err := time.Parse(format, dateStr); err == nil {
-			return t, nil
-		}
+	switch {
+	case len(runs) > 0:
+		job.NextRunAt = &runs[0]
+	case !horizonReached.IsZero():
+		job.NextRunAt = &horizonReached
+	default:
+		return ErrJobScheduleElapsed
 	}
-
-	return time.Time{}, fmt.Errorf("unsupported datetime format: %s", dateStr)
+	return nil
 }
 
 func (s *JobService) calculateNextRunTime(job *models.Jobs) error {
@@ -196,6 +403,9 @@ func (s *JobService) calculateNextRunTime(job *models.Jobs) error {
 		return s.calculateNextRunTimeForIntervalJob(job)
 	case models.JobTypeScheduled:
 		return s.calculateNextRunTimeForScheduledJob(job)
+	case models.JobTypeDependent, models.JobTypeWebhook:
+		job.NextRunAt = nil
+		return nil
 	default:
 		return fmt.Errorf("unsupported job type: %s", job.Type)
 	}
@@ -207,6 +417,7 @@ func (s *JobService) calculateNextRunTime(job *models.Jobs) error {
 type GetJobsRequest struct {
 	UserId      string
 	WorkspaceId string
+	Status      models.JobStatus // Optional status filter
 	Page        int
 	Limit       int
 }
@@ -236,16 +447,19 @@ func (s *JobService) GetJobs(ctx context.Context, req *GetJobsRequest) (*GetJobs
 	var jobs []models.Jobs
 	var totalCount int64
 
+	scope := s.db.GORM.Where("user_id = ? AND workspace_id = ? AND is_deleted = false", uuid.MustParse(req.UserId), uuid.MustParse(req.WorkspaceId))
+	if req.Status != "" {
+		scope = scope.Where("status = ?", req.Status)
+	}
+
 	// Get total count
-	countResult := s.db.GORM.Model(&models.Jobs{}).
-		Where("user_id = ? AND workspace_id = ? AND is_deleted = false", uuid.MustParse(req.UserId), uuid.MustParse(req.WorkspaceId)).
-		Count(&totalCount)
+	countResult := scope.Session(&gorm.Session{}).Model(&models.Jobs{}).Count(&totalCount)
 	if countResult.Error != nil {
 		return nil, fmt.Errorf("failed to count jobs: %w", countResult.Error)
 	}
 
 	// Get paginated jobs
-	result := s.db.GORM.Where("user_id = ? AND workspace_id = ? AND is_deleted = false", uuid.MustParse(req.UserId), uuid.MustParse(req.WorkspaceId)).
+	result := scope.Session(&gorm.Session{}).
 		Offset(offset).
 		Limit(req.Limit).
 		Order("created_at DESC").
@@ -336,16 +550,50 @@ func (s *JobService) GetJob(ctx context.Context, req *GetJobRequest) (*GetJobRes
 	}, nil
 }
 
+// GetOwnedJob returns a non-deleted job owned by the user
+func (s *JobService) GetOwnedJob(ctx context.Context, id uuid.UUID, userId uuid.UUID) (*models.Jobs, error) {
+	job := &models.Jobs{}
+	if err := s.db.GORM.Where("id = ? AND user_id = ? AND is_deleted = false", id, userId).First(job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrJobNotFound
+		}
+		return nil, err
+	}
+	return job, nil
+}
+
 func (s *JobService) IsJobActive(ctx context.Context, id uuid.UUID) (bool, error) {
+	job, err := s.GetActiveJob(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	return job != nil, nil
+}
+
+// GetActiveJob returns the job if it is active, or nil if it was paused or deleted
+func (s *JobService) GetActiveJob(ctx context.Context, id uuid.UUID) (*models.Jobs, error) {
+	return s.getJobInStatus(ctx, id, models.JobStatusActive)
+}
+
+// GetRunnableJob returns the job if a run started by trigger may execute, or nil.
+// Recovered misfires of a job that completed meanwhile still run: they were due before it completed.
+func (s *JobService) GetRunnableJob(ctx context.Context, id uuid.UUID, trigger models.TaskTrigger) (*models.Jobs, error) {
+	if trigger == models.TaskTriggerMisfire {
+		return s.getJobInStatus(ctx, id, models.JobStatusActive, models.JobStatusCompleted)
+	}
+	return s.getJobInStatus(ctx, id, models.JobStatusActive)
+}
+
+func (s *JobService) getJobInStatus(ctx context.Context, id uuid.UUID, statuses ...models.JobStatus) (*models.Jobs, error) {
 	job := &models.Jobs{}
-	if err := s.db.GORM.Where("id = ? AND status = 'active' AND is_deleted = false", id).First(job).Error; err != nil {
+	if err := s.db.GORM.Where("id = ? AND status IN ? AND is_deleted = false", id, statuses).First(job).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			log.Println("job not found", id)
-			return false, nil
+			return nil, nil
 		}
-		return false, err
+		return nil, err
 	}
-	return true, nil
+	return job, nil
 }
 
 // DeleteJob
@@ -411,24 +659,19 @@ func (s *JobService) DeleteJob(ctx context.Context, req *DeleteJobRequest) error
 	return nil
 }
 
+// RescheduleIntervalJob schedules the next run of an interval job, or completes the job
+// when its window is closed or its max_runs are used
 func (s *JobService) RescheduleIntervalJob(ctx context.Context, job *models.Jobs) error {
-	var intervalData models.IntervalData
-	if err := json.Unmarshal([]byte(*job.Interval), &intervalData); err != nil {
-		return err
-	}
-
-	schedule, err := cron.ParseStandard(*intervalData.Value)
-	if err != nil {
+	if err := s.calculateNextRunTimeForIntervalJob(job); err != nil {
+		if errors.Is(err, ErrJobScheduleElapsed) {
+			return s.CompleteJob(ctx, job)
+		}
 		return err
 	}
-
-	now := time.Now()
-	nextRun := schedule.Next(now)
-	job.NextRunAt = &nextRun
 	job.UpdatedAt = time.Now()
 
 	// Schedule in River
-	err = GetRiverClientInstance(s.db).ScheduleJobInRiver(ctx, job)
+	err := GetRiverClientInstance(s.db).ScheduleJobInRiver(ctx, job)
 	if err != nil {
 		return err
 	}
@@ -442,17 +685,439 @@ func (s *JobService) RescheduleIntervalJob(ctx context.Context, job *models.Jobs
 	return nil
 }
 
+// CompleteJob moves an active job to the completed status, it has no run left to schedule
+func (s *JobService) CompleteJob(ctx context.Context, job *models.Jobs) error {
+	job.Status = models.JobStatusCompleted
+	job.NextRunAt = nil
+	job.UpdatedAt = time.Now()
+
+	query := `UPDATE jobs SET status = 'completed', next_run_at = NULL, updated_at = $1, version = version + 1 WHERE id = $2 AND status = 'active' AND is_deleted = false`
+	if err := s.db.GORM.Exec(query, job.UpdatedAt, job.ID).Error; err != nil {
+		return fmt.Errorf("failed to complete job %s: %w", job.ID, err)
+	}
+	log.Printf("Job %s completed after %d scheduled runs", job.ID, job.RunCount)
+	return nil
+}
+
+// IncrementRunCount counts a scheduled run of a job against its max_runs
+func (s *JobService) IncrementRunCount(ctx context.Context, jobID uuid.UUID) error {
+	query := `UPDATE jobs SET run_count = run_count + 1 WHERE id = $1`
+	if err := s.db.GORM.Exec(query, jobID).Error; err != nil {
+		return fmt.Errorf("failed to increment run count of job %s: %w", jobID, err)
+	}
+	return nil
+}
+
+// UpdateJob
+
+// UpdateJob edits a job guarded by its version, recomputes the next run and
+// replaces the pending River job in the same transaction.
+func (s *JobService) UpdateJob(ctx context.Context, id uuid.UUID, userId uuid.UUID, version int64, req *models.UpdateJobRequest) (*models.Jobs, error) {
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	job, err := lockOwnedJob(ctx, tx, id, userId)
+	if err != nil {
+		return nil, err
+	}
+	if job.Version != version {
+		return nil, ErrJobVersionConflict
+	}
+
+	if req.Name != nil {
+		job.Name = *req.Name
+	}
+	if req.Payload != nil {
+		job.Payload = *req.Payload
+	}
+	if req.Schedule != nil {
+		job.Schedule = req.Schedule
+	}
+	if req.Interval != nil {
+		job.Interval = req.Interval
+	}
+	if req.OverlapPolicy != nil {
+		job.OverlapPolicy = *req.OverlapPolicy
+	}
+	if req.RetryPolicy != nil {
+		job.RetryPolicy = req.RetryPolicy
+		if *req.RetryPolicy == "" {
+			job.RetryPolicy = nil
+		}
+	}
+	if req.Timezone != nil {
+		job.Timezone = *req.Timezone
+	}
+	startsAt, endsAt := formatJobTime(job.StartsAt), formatJobTime(job.EndsAt)
+	if req.StartsAt != nil {
+		startsAt = req.StartsAt
+	}
+	if req.EndsAt != nil {
+		endsAt = req.EndsAt
+	}
+	if req.MaxRuns != nil {
+		job.MaxRuns = req.MaxRuns
+		if *req.MaxRuns == 0 {
+			job.MaxRuns = nil
+		}
+	}
+	if req.MisfirePolicy != nil {
+		job.MisfirePolicy = *req.MisfirePolicy
+	}
+	if req.MisfireLimit != nil {
+		job.MisfireLimit = *req.MisfireLimit
+	}
+	if req.CalendarIDs != nil {
+		job.CalendarIDs = *req.CalendarIDs
+	}
+	if req.JitterSeconds != nil {
+		job.JitterSeconds = req.JitterSeconds
+		if *req.JitterSeconds == -1 {
+			job.JitterSeconds = nil
+		}
+	}
+	if req.DependsOn != nil {
+		job.DependsOn = *req.DependsOn
+	}
+	if req.PlanFailurePolicy != nil {
+		job.PlanFailurePolicy = *req.PlanFailurePolicy
+		if job.PlanFailurePolicy == "" {
+			job.PlanFailurePolicy = models.PlanFailurePolicyBestEffort
+		}
+	}
+
+	if err := s.validateJobRequest(&models.CreateJobRequest{
+		Name:          job.Name,
+		WorkspaceID:   job.WorkspaceID,
+		Payload:       job.Payload,
+		Type:          job.Type,
+		Schedule:      job.Schedule,
+		Interval:      job.Interval,
+		OverlapPolicy: job.OverlapPolicy,
+		RetryPolicy:   job.RetryPolicy,
+		Timezone:      job.Timezone,
+		StartsAt:      startsAt,
+		EndsAt:        endsAt,
+		MaxRuns:       job.MaxRuns,
+		MisfirePolicy: job.MisfirePolicy,
+		MisfireLimit:  job.MisfireLimit,
+		CalendarIDs:   job.CalendarIDs,
+		JitterSeconds: job.JitterSeconds,
+		DependsOn:     job.DependsOn,
+
+		PlanFailurePolicy: job.PlanFailurePolicy,
+	}, job.UserID); err != nil {
+		// A prompt saved before templates were checked is only checked again when the payload changes
+		if req.Payload == nil {
+			err = withoutField(err, "payload.prompt")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if req.DependsOn != nil {
+		if err := lockUserDependencies(ctx, tx, job.UserID); err != nil {
+			return nil, err
+		}
+		if err := s.checkDependencyCycle(ctx, tx, job.ID, job.DependsOn); err != nil {
+			return nil, err
+		}
+	}
+	if err := setRunWindow(job, startsAt, endsAt); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidJobRequest, err)
+	}
+
+	// A one-shot job keeps its (possibly past) run time unless its schedule, timezone or calendars changed.
+	// An active interval job left without runs by its new window or max_runs completes.
+	if job.Type == models.JobTypeInterval || req.Schedule != nil || req.Timezone != nil || req.CalendarIDs != nil {
+		err := s.calculateNextRunTime(job)
+		switch {
+		case errors.Is(err, ErrJobScheduleElapsed) && job.Status == models.JobStatusActive:
+			job.Status = models.JobStatusCompleted
+			job.NextRunAt = nil
+		case err != nil:
+			return nil, fmt.Errorf("%w: %v", ErrInvalidJobRequest, err)
+		}
+	}
+
+	if job.Status == models.JobStatusActive || job.Status == models.JobStatusCompleted {
+		// Only the scheduled run is replaced: manual, dependency, webhook runs and retries stay queued
+		riverClient := GetRiverClientInstance(s.db)
+		if err := riverClient.DeleteScheduledRunsTx(ctx, tx, job.ID); err != nil {
+			return nil, err
+		}
+		if job.Status == models.JobStatusActive && job.NextRunAt != nil && job.NextRunAt.After(time.Now()) {
+			if err := riverClient.ScheduleJobInRiverTx(ctx, tx, job); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	job.UpdatedAt = time.Now()
+	query := `UPDATE jobs SET name = $1, payload = $2, schedule = $3, "interval" = $4, overlap_policy = $5, retry_policy = $6, timezone = $7, next_run_at = $8, river_job_id = $9, updated_at = $10,
+		starts_at = $11, ends_at = $12, max_runs = $13, status = $14, misfire_policy = $15, misfire_limit = $16, calendar_ids = $17, jitter_seconds = $18,
+		depends_on = $19, plan_failure_policy = $20, version = version + 1
+		WHERE id = $21 AND version = $22`
+	tag, err := tx.Exec(ctx, query, job.Name, job.Payload, job.Schedule, job.Interval, job.OverlapPolicy, job.RetryPolicy, job.Timezone, job.NextRunAt, job.RiverJobID, job.UpdatedAt,
+		job.StartsAt, job.EndsAt, job.MaxRuns, job.Status, job.MisfirePolicy, job.MisfireLimit, job.CalendarIDs, job.JitterSeconds, job.DependsOn, job.PlanFailurePolicy, job.ID, version)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrJobVersionConflict
+	}
+	job.Version = version + 1
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// ValidateJob
+
+// ValidationPreviewRuns is the number of upcoming runs returned by ValidateJob
+const ValidationPreviewRuns = 10
+
+type ValidateJobResponse struct {
+	Valid       bool            `json:"valid"`
+	Errors      []FieldError    `json:"errors"`
+	Description string          `json:"description,omitempty"`
+	Timezone    string          `json:"timezone,omitempty"`
+	NextRuns    []time.Time     `json:"next_runs"`
+	Suppressed  []SuppressedRun `json:"suppressed"` // Occurrences blacked out by calendars
+	Warnings    []string        `json:"warnings"`
+}
+
+// ValidateJob runs the validation and next run computation of CreateJob without persisting anything
+func (s *JobService) ValidateJob(req *models.CreateJobRequest, userId uuid.UUID) (*ValidateJobResponse, error) {
+	resp := &ValidateJobResponse{
+		Errors:     []FieldError{},
+		NextRuns:   []time.Time{},
+		Suppressed: []SuppressedRun{},
+		Warnings:   []string{},
+	}
+
+	if err := s.validateJobRequest(req, userId); err != nil {
+		var verr *ValidationError
+		if !errors.As(err, &verr) {
+			return nil, err
+		}
+		resp.Errors = verr.Errors
+		return resp, nil
+	}
+
+	now := time.Now()
+	job := &models.Jobs{
+		Type:        req.Type,
+		Schedule:    req.Schedule,
+		Interval:    req.Interval,
+		Timezone:    req.Timezone,
+		MaxRuns:     req.MaxRuns,
+		WorkspaceID: req.WorkspaceID,
+		CalendarIDs: req.CalendarIDs,
+		DependsOn:   req.DependsOn,
+		CreatedAt:   now,
+	}
+	if job.Timezone == "" {
+		job.Timezone = DefaultJobTimezone
+	}
+	if err := setRunWindow(job, req.StartsAt, req.EndsAt); err != nil {
+		return nil, err
+	}
+	if err := s.calculateNextRunTime(job); err != nil {
+		field := "interval"
+		switch {
+		case errors.Is(err, ErrJobScheduleElapsed):
+			field = "ends_at"
+		case job.Type == models.JobTypeScheduled:
+			field = "schedule.execute_at"
+		}
+		resp.Errors = append(resp.Errors, FieldError{Field: field, Message: err.Error()})
+		return resp, nil
+	}
+
+	calendars, err := loadJobCalendars(s.db, job)
+	if err != nil {
+		return nil, err
+	}
+	runs, suppressed, err := nextRunTimes(job, now, ValidationPreviewRuns, calendars)
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Valid = true
+	resp.Description = describeJobSchedule(job)
+	resp.Timezone = job.Timezone
+	resp.NextRuns = runs
+	resp.Suppressed = suppressed
+	resp.Warnings = uniquenessWarnings(runs)
+	return resp, nil
+}
+
+// uniquenessWarnings flags runs closer to each other than River's uniqueness window,
+// River keeps only one run per window
+func uniquenessWarnings(runs []time.Time) []string {
+	warnings := []string{}
+	for i := 1; i < len(runs); i++ {
+		if runs[i].Sub(runs[i-1]) < riverUniquePeriod {
+			warnings = append(warnings, fmt.Sprintf("runs at %s and %s are less than %s apart: River's uniqueness window keeps only one of them",
+				runs[i-1].Format(time.RFC3339), runs[i].Format(time.RFC3339), riverUniquePeriod))
+		}
+	}
+	return warnings
+}
+
+// NextRuns
+
+type NextRunsResponse struct {
+	JobID      uuid.UUID       `json:"job_id"`
+	Timezone   string          `json:"timezone"`
+	NextRuns   []time.Time     `json:"next_runs"`
+	Suppressed []SuppressedRun `json:"suppressed"`
+}
+
+// GetNextRuns returns the next run times of a job, expressed in the job's timezone
+func (s *JobService) GetNextRuns(ctx context.Context, id uuid.UUID, userId uuid.UUID, count int) (*NextRunsResponse, error) {
+	job, err := s.GetOwnedJob(ctx, id, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	calendars, err := loadJobCalendars(s.db, job)
+	if err != nil {
+		return nil, err
+	}
+	runs, suppressed, err := nextRunTimes(job, time.Now(), min(count, MaxNextRunsPreview), calendars)
+	if err != nil {
+		return nil, err
+	}
+	return &NextRunsResponse{
+		JobID:      job.ID,
+		Timezone:   job.Timezone,
+		NextRuns:   runs,
+		Suppressed: suppressed,
+	}, nil
+}
+
+// GetTaskSteps returns the plan steps recorded for a task of a job owned by the user
+func (s *JobService) GetTaskSteps(ctx context.Context, jobID uuid.UUID, taskID uuid.UUID, userId uuid.UUID) ([]models.TaskSteps, error) {
+	if _, err := s.GetOwnedJob(ctx, jobID, userId); err != nil {
+		return nil, err
+	}
+	tasksService := NewTasksService(s.db)
+	if _, err := tasksService.GetTaskByJobID(taskID, jobID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTaskNotFound
+		}
+		return nil, err
+	}
+	return tasksService.GetTaskSteps(taskID)
+}
+
+// RunJobNow enqueues a manual execution of a job outside of its schedule.
+// The job's NextRunAt and pending scheduled run are left untouched.
+func (s *JobService) RunJobNow(ctx context.Context, id uuid.UUID, userId uuid.UUID) (int64, error) {
+	job, err := s.GetOwnedJob(ctx, id, userId)
+	if err != nil {
+		return 0, err
+	}
+	if job.Status != models.JobStatusActive {
+		return 0, ErrJobNotActive
+	}
+
+	return GetRiverClientInstance(s.db).EnqueueJobRun(ctx, job, models.TaskTriggerManual)
+}
+
+// lockOwnedJob loads a non-deleted job owned by the user and locks its row until tx ends
+func lockOwnedJob(ctx context.Context, tx pgx.Tx, id uuid.UUID, userId uuid.UUID) (*models.Jobs, error) {
+	rows, err := tx.Query(ctx, `SELECT * FROM jobs WHERE id = $1 AND user_id = $2 AND is_deleted = false FOR UPDATE`, id, userId)
+	if err != nil {
+		return nil, err
+	}
+	job, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByNameLax[models.Jobs])
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrJobNotFound
+		}
+		return nil, err
+	}
+	return job, nil
+}
+
 // Pause/Resume jobs
+
+// PauseJob deactivates a job and removes its pending scheduled run. Other queued runs stay in River
+// and are dropped by the worker if they come up while the job is paused. A run that is already
+// executing is allowed to finish.
 func (s *JobService) PauseJob(ctx context.Context, id uuid.UUID, userId uuid.UUID) error {
-	query := `UPDATE jobs SET status = 'inactive', updated_at = $1 WHERE id = $2 AND user_id = $3`
-	err := s.db.GORM.Exec(query, time.Now(), id, userId).Error
-	return err
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	job, err := lockOwnedJob(ctx, tx, id, userId)
+	if err != nil {
+		return err
+	}
+	if job.Status != models.JobStatusActive {
+		return nil
+	}
+
+	if err := GetRiverClientInstance(s.db).DeleteScheduledRunsTx(ctx, tx, job.ID); err != nil {
+		return err
+	}
+
+	query := `UPDATE jobs SET status = 'inactive', next_run_at = NULL, updated_at = $1, version = version + 1 WHERE id = $2`
+	if _, err := tx.Exec(ctx, query, time.Now(), job.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
+// ResumeJob reactivates a paused job and schedules its next run in River
 func (s *JobService) ResumeJob(ctx context.Context, id uuid.UUID, userId uuid.UUID) error {
-	query := `UPDATE jobs SET status = 'active', updated_at = $1 WHERE id = $2 AND user_id = $3`
-	err := s.db.GORM.Exec(query, time.Now(), id, userId).Error
-	return err
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	job, err := lockOwnedJob(ctx, tx, id, userId)
+	if err != nil {
+		return err
+	}
+	if job.Status == models.JobStatusActive {
+		return nil
+	}
+
+	// ErrJobScheduleElapsed answers 409, any other failure is returned as is
+	if err := s.calculateNextRunTime(job); err != nil {
+		return err
+	}
+
+	riverClient := GetRiverClientInstance(s.db)
+	// Clear leftover scheduled runs of jobs paused before pausing removed them
+	if err := riverClient.DeleteScheduledRunsTx(ctx, tx, job.ID); err != nil {
+		return err
+	}
+	if job.NextRunAt != nil {
+		if err := riverClient.ScheduleJobInRiverTx(ctx, tx, job); err != nil {
+			return err
+		}
+	}
+
+	query := `UPDATE jobs SET status = 'active', next_run_at = $1, river_job_id = $2, updated_at = $3, version = version + 1 WHERE id = $4`
+	if _, err := tx.Exec(ctx, query, job.NextRunAt, job.RiverJobID, time.Now(), job.ID); err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
 func (s *JobService) GetJobsForWorker() ([]models.Jobs, error) {
@@ -485,11 +1150,11 @@ func (s *JobService) HasRunningTasks(ctx context.Context, jobID uuid.UUID) (bool
 	result := s.db.GORM.Model(&models.Tasks{}).
 		Where("job_id = ? AND status = ? AND is_deleted = false", jobID, models.TaskStatusRunning).
 		Count(&count)
-	
+
 	if result.Error != nil {
 		return false, fmt.Errorf("failed to check running tasks for job %s: %w", jobID, result.Error)
 	}
-	
+
 	return count > 0, nil
 }
 
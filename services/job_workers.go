@@ -11,7 +11,7 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"sort"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/riverqueue/river"
@@ -30,15 +30,57 @@ func NewIntervalJobWorker(jobService *JobService, tasksService *TasksService) *I
 	}
 }
 
+// Overall deadline of one job run, configurable per job through Payload.TimeoutSeconds.
+// MaxJobTimeout stays below River's RescueStuckJobsAfter (1 hour) so a long run is
+// never rescued and executed twice.
+const (
+	DefaultJobTimeout = 30 * time.Minute
+	MaxJobTimeout     = 50 * time.Minute
+)
+
+// jobTimeoutGrace leaves time after the run deadline to cancel the agent task and store the outcome
+const jobTimeoutGrace = 1 * time.Minute
+
+// jobTimeout returns the run deadline configured in the payload
+func jobTimeout(payload models.Payload) time.Duration {
+	if payload.TimeoutSeconds <= 0 {
+		return DefaultJobTimeout
+	}
+	return min(time.Duration(payload.TimeoutSeconds)*time.Second, MaxJobTimeout)
+}
+
+// Timeout overrides River's default job timeout so it matches the per-job deadline
+func (w *IntervalJobWorker) Timeout(job *river.Job[shared.IntervalJobArgs]) time.Duration {
+	payload := models.Payload{}
+	if err := json.Unmarshal([]byte(job.Args.Payload), &payload); err != nil {
+		return DefaultJobTimeout + jobTimeoutGrace
+	}
+	return jobTimeout(payload) + jobTimeoutGrace
+}
+
+// NextRetry applies the job's retry policy backoff instead of River's default
+func (w *IntervalJobWorker) NextRetry(job *river.Job[shared.IntervalJobArgs]) time.Time {
+	if job.Args.RetryPolicy == nil {
+		return time.Time{}
+	}
+	return time.Now().Add(retryDelay(job.Args.RetryPolicy, job.Attempt))
+}
+
 func (w *IntervalJobWorker) Work(ctx context.Context, job *river.Job[shared.IntervalJobArgs]) error {
 	log.Printf("Executing scheduled job: (ID: %s)", job.Args.JobID)
 
-	isExistedJob, err := w.jobService.IsJobActive(ctx, job.Args.JobID)
+	trigger := models.TaskTrigger(job.Args.Trigger)
+	if trigger == "" {
+		trigger = models.TaskTriggerSchedule
+	}
+
+	dbJob, err := w.jobService.GetRunnableJob(ctx, job.Args.JobID, trigger)
 	if err != nil {
 		log.Printf("Failed to check if job is active: %v", err)
 		return err
 	}
-	if !isExistedJob {
+	if dbJob == nil {
+		w.cancelWaitingTask(job)
 		_ = river.JobCancel(fmt.Errorf("Job %s is no longer active", job.Args.JobID))
 		return nil
 	}
@@ -49,10 +91,118 @@ func (w *IntervalJobWorker) Work(ctx context.Context, job *river.Job[shared.Inte
 		return err
 	}
 
-	// Create task
-	taskID, err := w.tasksService.CreateTask(job.Args.JobID, job.Args.Payload)
+
+	// The next occurrence of a scheduled run is queued once, when the run starts, so a slow run
+	// overlaps it and the overlap policy applies. Resumes keep the trigger of the task they resume
+	// but never move the schedule.
+	resume := job.Args.ResumeTaskID != nil
+	scheduledRun := trigger == models.TaskTriggerSchedule && !resume
+	firstScheduledAttempt := scheduledRun && job.Attempt <= 1
+
+	// Checks against the schedule use the occurrence the run stands for, before jitter. Runs queued
+	// without it only had the job's current jitter to go by.
+	scheduledFor := job.ScheduledAt
+	if job.Args.ScheduledFor != nil {
+		scheduledFor = *job.Args.ScheduledFor
+	} else if firstScheduledAttempt {
+		jitter, err := jobJitterOffset(w.jobService.db, dbJob)
+		if err != nil {
+			return err
+		}
+		scheduledFor = scheduledFor.Add(-jitter)
+	}
+
+	// A scheduled run left over after the job's window closed or its max_runs were used completes the job
+	if firstScheduledAttempt && JobRunLimitReached(dbJob, scheduledFor) {
+		if err := w.jobService.CompleteJob(ctx, dbJob); err != nil {
+			return err
+		}
+		return river.JobCancel(fmt.Errorf("job %s has no run left", job.Args.JobID))
+	}
+
+	// Calendars may have changed since this run was scheduled
+	if firstScheduledAttempt {
+		if skipped, err := w.skipBlackedOutRun(ctx, job, dbJob, scheduledFor); skipped || err != nil {
+			return err
+		}
+	}
+
+	// Retries keep running under the task created by the first attempt, with the prompt rendered for it.
+	// A resume is the next attempt of the task it resumes.
+	var taskID uuid.UUID
+	var runPayload string
+	attempt := job.Attempt
+	if resume {
+		task, err := w.tasksService.GetTaskByID(*job.Args.ResumeTaskID)
+		if err != nil {
+			log.Printf("Failed to load task %s to resume: %v", *job.Args.ResumeTaskID, err)
+			return err
+		}
+		if task == nil {
+			return river.JobCancel(fmt.Errorf("task %s to resume no longer exists", *job.Args.ResumeTaskID))
+		}
+		taskID = task.ID
+		runPayload = task.Payload
+		attempt = task.Attempt + 1
+	} else if job.Attempt > 1 {
+		task, err := w.tasksService.GetTaskByRiverJobID(job.ID)
+		if err != nil {
+			log.Printf("Failed to load task of River job %d: %v", job.ID, err)
+			return err
+		}
+		if task != nil {
+			taskID = task.ID
+			runPayload = task.Payload
+		}
+	}
+
+	if taskID == uuid.Nil {
+		if proceed, err := w.applyOverlapPolicy(ctx, job, dbJob, trigger); !proceed {
+			return err
+		}
+
+		// A retry runs the payload already rendered for the task it retries
+		if trigger == models.TaskTriggerRetry {
+			runPayload = job.Args.Payload
+		} else {
+			runPayload, err = w.renderRunPayload(job, dbJob, &payload, trigger)
+			if err != nil {
+				log.Printf("Failed to render prompt of job %s: %v", job.Args.JobID, err)
+				return err
+			}
+		}
+
+		// Create task
+		taskID, err = w.tasksService.CreateTask(&CreateTaskRequest{
+			JobID:          job.Args.JobID,
+			Payload:        runPayload,
+			Prompt:         payload.Prompt,
+			Trigger:        trigger,
+			RiverJobID:     job.ID,
+			UpstreamTaskID: job.Args.UpstreamTaskID,
+			RetryOfTaskID:  job.Args.RetryOfTaskID,
+			Request:        job.Args.Webhook,
+			ScheduledFor:   job.Args.ScheduledFor,
+		})
+		if err != nil {
+			log.Printf("Failed to create task: %v", err)
+			return err
+		}
+
+		// Recovered misfires stand for scheduled runs and count against max_runs too
+		if trigger == models.TaskTriggerSchedule || trigger == models.TaskTriggerMisfire {
+			if err := w.jobService.IncrementRunCount(ctx, job.Args.JobID); err != nil {
+				log.Printf("Failed to count run of job %s: %v", job.Args.JobID, err)
+			}
+		}
+		if scheduledRun {
+			w.rescheduleJobIfNeeded(ctx, job.Args.JobID)
+		}
+	}
+
+	attemptID, err := w.tasksService.CreateTaskAttempt(taskID, attempt)
 	if err != nil {
-		log.Printf("Failed to create task: %v", err)
+		log.Printf("Failed to record attempt %d of task %s: %v", attempt, taskID, err)
 		return err
 	}
 
@@ -65,39 +215,78 @@ func (w *IntervalJobWorker) Work(ctx context.Context, job *river.Job[shared.Inte
 	processJobArgs := shared.ProcessJobArgs{
 		JobID:       job.Args.JobID,
 		TaskID:      taskID,
+		Attempt:     attempt,
 		UserID:      job.Args.UserID,
 		WorkspaceID: job.Args.WorkspaceID,
-		Payload:     job.Args.Payload,
+		Payload:     runPayload,
+		Resume:      resume,
+
+		PlanFailurePolicy: dbJob.PlanFailurePolicy,
 	}
 
+	runCtx, cancel := context.WithTimeout(ctx, jobTimeout(payload))
+	defer cancel()
+
 	var processErr error
 	var result interface{}
-	switch payload.ResourceName {
-	case models.AIAgent: // ai_agent
-		log.Printf("Processing AI agent job %s", job.Args.JobID)
-		result, processErr = processAIAgentJob(processJobArgs, w.tasksService)
-	case models.ClientAgent: // client_agent
-		log.Printf("Processing Client agent job %s", job.Args.JobID)
-		result, processErr = processClientAgentJob(processJobArgs, w.tasksService)
-	default:
+	if executor, ok := GetResourceExecutor(payload.ResourceName); ok {
+		log.Printf("Processing %s job %s", payload.ResourceName, job.Args.JobID)
+		result, processErr = executor.Execute(runCtx, processJobArgs, w.tasksService)
+	} else {
 		processErr = fmt.Errorf("unknown resource type: %s", payload.ResourceName)
 	}
 
 	if processErr != nil {
-		log.Printf("Job %s failed: %v", job.Args.JobID, processErr)
-		// Update both task and job status to failed
-		if err := w.tasksService.UpdateTaskById(taskID, models.TaskStatusFailed); err != nil {
-			log.Printf("Failed to update task status to failed: %v", err)
+		class := classifyError(processErr)
+		retrying := shouldRetry(job.Args.RetryPolicy, class) && job.Attempt < job.MaxAttempts
+		log.Printf("Job %s failed on attempt %d/%d (%s): %v", job.Args.JobID, job.Attempt, job.MaxAttempts, class, processErr)
+
+		failedStatus := models.TaskStatusFailed
+		switch {
+		case errors.Is(processErr, context.DeadlineExceeded):
+			failedStatus = models.TaskStatusTimedOut
+		case errors.Is(processErr, context.Canceled):
+			failedStatus = models.TaskStatusCanceled
+		}
+		if err := w.tasksService.FinishTaskAttempt(attemptID, failedStatus, processErr, class); err != nil {
+			log.Printf("Failed to finish attempt %d of task %s: %v", attempt, taskID, err)
+		}
+
+		taskStatus := failedStatus
+		if retrying {
+			taskStatus = models.TaskStatusRetrying
+		}
+		if err := w.tasksService.UpdateTaskById(taskID, taskStatus); err != nil {
+			log.Printf("Failed to update task status to %s: %v", taskStatus, err)
 		}
-		
+
 		// ✅ ADD: Clear current task ID when job fails
 		if err := w.jobService.UpdateCurrentTaskID(ctx, job.Args.JobID, nil); err != nil {
 			log.Printf("Failed to clear current task ID for failed job %s: %v", job.Args.JobID, err)
 		}
-		
+
+		if retrying {
+			// River retries the job after NextRetry
+			return processErr
+		}
+		// Dependents already ran for the failure of a resumed task
+		if !resume {
+			w.jobService.TriggerDependentJobs(ctx, job.Args.JobID, taskID, failedStatus)
+		}
+		if job.Attempt < job.MaxAttempts {
+			// Not retryable: stop River from using the remaining attempts
+			return river.JobCancel(processErr)
+		}
 		return processErr
 	}
 
+	// A partial result stores what succeeded and marks the task partially completed
+	status := models.TaskStatusCompleted
+	if partial, ok := result.(PartialResult); ok {
+		status = models.TaskStatusPartiallyCompleted
+		result = partial.Result
+	}
+
 	// Convert result to string for storage
 	var resultStr string
 	if str, ok := result.(string); ok {
@@ -110,22 +299,209 @@ func (w *IntervalJobWorker) Work(ctx context.Context, job *river.Job[shared.Inte
 		}
 		resultStr = string(resultJSON)
 	}
-	if err := w.tasksService.UpdateTaskResult(taskID, resultStr, models.TaskStatusCompleted); err != nil {
+	if err := w.tasksService.FinishTaskAttempt(attemptID, status, nil, ""); err != nil {
+		log.Printf("Failed to finish attempt %d of task %s: %v", attempt, taskID, err)
+	}
+	if err := w.tasksService.UpdateTaskResult(taskID, resultStr, status); err != nil {
 		return err
 	}
+	w.jobService.TriggerDependentJobs(ctx, job.Args.JobID, taskID, status)
 
-	log.Printf("Job %s completed successfully", job.Args.JobID)
+	log.Printf("Job %s finished: %s", job.Args.JobID, status)
 	
 	// ✅ ADD: Clear current task ID when job completes successfully
 	if err := w.jobService.UpdateCurrentTaskID(ctx, job.Args.JobID, nil); err != nil {
 		log.Printf("Failed to clear current task ID for completed job %s: %v", job.Args.JobID, err)
 	}
 	
-	// ✅ ADD: Still reschedule even if job failed (for retry)
-	w.rescheduleJobIfNeeded(ctx, job.Args.JobID)
 	return nil
 }
 
+// cancelWaitingTask marks canceled the task a resume or a River retry was going to run, so it does
+// not stay retrying when the job was paused or deleted meanwhile
+func (w *IntervalJobWorker) cancelWaitingTask(job *river.Job[shared.IntervalJobArgs]) {
+	var taskID uuid.UUID
+	switch {
+	case job.Args.ResumeTaskID != nil:
+		taskID = *job.Args.ResumeTaskID
+	case job.Attempt > 1:
+		task, err := w.tasksService.GetTaskByRiverJobID(job.ID)
+		if err != nil {
+			log.Printf("Failed to load task of River job %d: %v", job.ID, err)
+			return
+		}
+		if task == nil {
+			return
+		}
+		taskID = task.ID
+	default:
+		return
+	}
+	if _, err := w.tasksService.CancelPendingTask(taskID); err != nil {
+		log.Printf("Failed to cancel task %s of inactive job %s: %v", taskID, job.Args.JobID, err)
+	}
+}
+
+// overlapQueueSnooze is how long a queued run waits before checking the running task again
+const overlapQueueSnooze = 15 * time.Second
+
+// applyOverlapPolicy enforces the job's overlap policy against tasks that are still running.
+// It reports whether this run may start; when it may not, the returned error is what Work returns.
+func (w *IntervalJobWorker) applyOverlapPolicy(ctx context.Context, job *river.Job[shared.IntervalJobArgs], dbJob *models.Jobs, trigger models.TaskTrigger) (bool, error) {
+	if dbJob.OverlapPolicy == "" || dbJob.OverlapPolicy == models.OverlapPolicyAllow {
+		return true, nil
+	}
+
+	runningTasks, err := w.tasksService.GetRunningTasksByJobID(dbJob.ID)
+	if err != nil {
+		return false, err
+	}
+	if len(runningTasks) == 0 {
+		return true, nil
+	}
+
+	switch dbJob.OverlapPolicy {
+	case models.OverlapPolicySkip:
+		log.Printf("Job %s is still running task %s, skipping this run", dbJob.ID, runningTasks[0].ID)
+		taskID, err := w.tasksService.CreateTask(&CreateTaskRequest{
+			JobID:          dbJob.ID,
+			Payload:        job.Args.Payload,
+			Trigger:        trigger,
+			RiverJobID:     job.ID,
+			UpstreamTaskID: job.Args.UpstreamTaskID,
+			RetryOfTaskID:  job.Args.RetryOfTaskID,
+			Request:        job.Args.Webhook,
+		})
+		if err != nil {
+			return false, err
+		}
+		reason := fmt.Sprintf("Skipped: task %s was still running", runningTasks[0].ID)
+		if err := w.tasksService.UpdateTaskResult(taskID, reason, models.TaskStatusSkipped); err != nil {
+			return false, err
+		}
+		if trigger == models.TaskTriggerSchedule {
+			w.rescheduleJobIfNeeded(ctx, dbJob.ID)
+		}
+		return false, nil
+
+	case models.OverlapPolicyQueue:
+		log.Printf("Job %s is still running task %s, queueing this run", dbJob.ID, runningTasks[0].ID)
+		return false, river.JobSnooze(overlapQueueSnooze)
+
+	case models.OverlapPolicyReplace:
+		// Cancelling the River job cancels the run's context, which cancels the A2A task on the agent
+		riverClient := GetRiverClientInstance(w.jobService.db)
+		for _, task := range runningTasks {
+			if task.RiverJobID == 0 || task.RiverJobID == job.ID {
+				continue
+			}
+			log.Printf("Job %s replaces running task %s", dbJob.ID, task.ID)
+			if _, err := riverClient.Client.JobCancel(ctx, task.RiverJobID); err != nil {
+				log.Printf("Failed to cancel River job %d of task %s: %v", task.RiverJobID, task.ID, err)
+			}
+		}
+		return true, nil
+	}
+
+	return true, nil
+}
+
+// skipBlackedOutRun records a skipped task and reschedules the job when one of its calendars
+// blacks out the time this run was scheduled for. It reports whether the run was skipped.
+func (w *IntervalJobWorker) skipBlackedOutRun(ctx context.Context, job *river.Job[shared.IntervalJobArgs], dbJob *models.Jobs, scheduledFor time.Time) (bool, error) {
+	calendars, err := loadJobCalendars(w.jobService.db, dbJob)
+	if err != nil {
+		return false, err
+	}
+	reason := calendars.suppression(scheduledFor)
+	if reason == "" {
+		return false, nil
+	}
+
+	log.Printf("Job %s run at %s is blacked out, skipping it: %s", dbJob.ID, scheduledFor.Format(time.RFC3339), reason)
+	taskID, err := w.tasksService.CreateTask(&CreateTaskRequest{
+		JobID:      dbJob.ID,
+		Payload:    job.Args.Payload,
+		Trigger:    models.TaskTriggerSchedule,
+		RiverJobID: job.ID,
+	})
+	if err != nil {
+		return false, err
+	}
+	if err := w.tasksService.UpdateTaskResult(taskID, "Skipped: "+reason, models.TaskStatusSkipped); err != nil {
+		return false, err
+	}
+	w.rescheduleJobIfNeeded(ctx, dbJob.ID)
+	return true, nil
+}
+
+// renderRunPayload renders the prompt template of a run, and the templates of its resource_data,
+// into payload and returns the payload of the run. A dependency run whose prompt does not use the
+// upstream.* variables gets the result of the upstream task appended instead.
+func (w *IntervalJobWorker) renderRunPayload(job *river.Job[shared.IntervalJobArgs], dbJob *models.Jobs, payload *models.Payload, trigger models.TaskTrigger) (string, error) {
+	runCount, err := w.tasksService.CountRunsByJobID(dbJob.ID)
+	if err != nil {
+		return "", err
+	}
+	previous, err := w.tasksService.GetPreviousTask(dbJob.ID)
+	if err != nil {
+		return "", err
+	}
+	pc := &promptContext{
+		job:         dbJob,
+		now:         time.Now(),
+		runNumber:   int(runCount) + 1,
+		previous:    previous,
+		webhookBody: job.Args.WebhookBody,
+	}
+
+	if trigger == models.TaskTriggerDependency && job.Args.UpstreamTaskID != nil {
+		upstream, err := w.tasksService.GetTaskByID(*job.Args.UpstreamTaskID)
+		if err != nil {
+			return "", err
+		}
+		if upstream == nil {
+			return "", fmt.Errorf("upstream task %s not found", *job.Args.UpstreamTaskID)
+		}
+		pc.upstream = upstream
+		// Failed tasks keep no result, the error of their last attempt stands for it
+		if upstream.Result == "" && upstream.Status != models.TaskStatusCompleted {
+			if pc.upstreamErr, err = w.tasksService.GetLastAttemptError(upstream.ID); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	appendUpstream := pc.upstream != nil && !usesUpstream(payload.Prompt)
+	payload.Prompt = renderPrompt(payload.Prompt, pc)
+	if executor, ok := GetResourceExecutor(payload.ResourceName); ok {
+		if renderer, ok := executor.(ResourceDataRenderer); ok {
+			payload.ResourceData, err = renderer.RenderResourceData(payload.ResourceData, func(template string, escape func(string) string) (string, error) {
+				return renderTemplate(template, pc, escape), nil
+			})
+			if err != nil {
+				return "", err
+			}
+		}
+	}
+	if appendUpstream {
+		result := pc.upstream.Result
+		if result == "" {
+			result = pc.upstreamErr
+		}
+		payload.Prompt += fmt.Sprintf("\nUpstream result (%s): %s", pc.upstream.Status, result)
+	}
+	return marshalPayload(payload)
+}
+
+func marshalPayload(payload *models.Payload) (string, error) {
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal payload: %v", err)
+	}
+	return string(payloadJSON), nil
+}
+
 // ✅ ADD: Helper function to reschedule interval jobs
 func (w *IntervalJobWorker) rescheduleJobIfNeeded(ctx context.Context, jobID uuid.UUID) {
 	// Get the job from database
@@ -157,7 +533,7 @@ func (w *IntervalJobWorker) rescheduleJobIfNeeded(ctx context.Context, jobID uui
 	}
 }
 
-func processClientAgentJob(jobArgs shared.ProcessJobArgs, tasksService *TasksService) (interface{}, error) {
+func processClientAgentJob(ctx context.Context, jobArgs shared.ProcessJobArgs, tasksService *TasksService) (interface{}, error) {
 	payload := models.Payload{}
 	if err := json.Unmarshal([]byte(jobArgs.Payload), &payload); err != nil {
 		return nil, err
@@ -174,6 +550,16 @@ func processClientAgentJob(jobArgs shared.ProcessJobArgs, tasksService *TasksSer
 		return nil, err
 	}
 
+	policy, err := parsePlanFailurePolicy(jobArgs.PlanFailurePolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	// A resume runs the plan recorded for the task without asking the agent for a new one
+	if jobArgs.Resume {
+		return policy.result(resumeAgentPlan(ctx, jobArgs, clientAgentData.MaxParallelSteps, policy, tasksService))
+	}
+
 	// Prepare the request payload
 	requestBody := shared.ClientAgentRequest{
 		Message: payload.Prompt,
@@ -186,7 +572,12 @@ func processClientAgentJob(jobArgs shared.ProcessJobArgs, tasksService *TasksSer
 
 	// Make HTTP POST request to client agent
 	agentURL := clientAgentData.URL + "/messages"
-	resp, err := http.Post(agentURL, "application/json", bytes.NewBuffer(requestJSON))
+	req, err := http.NewRequestWithContext(ctx, "POST", agentURL, bytes.NewBuffer(requestJSON))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		log.Printf("Failed to send message: %v", err)
 		return nil, err
@@ -198,7 +589,11 @@ func processClientAgentJob(jobArgs shared.ProcessJobArgs, tasksService *TasksSer
 		var errorResponse map[string]interface{}
 		json.Unmarshal(bodyBytes, &errorResponse)
 
-		return nil, errors.New(errorResponse["error"].(string))
+		message, _ := errorResponse["error"].(string)
+		if message == "" {
+			message = string(bodyBytes)
+		}
+		return nil, &shared.HTTPStatusError{StatusCode: resp.StatusCode, Body: message}
 	}
 
 	// Parse response
@@ -212,137 +607,27 @@ func processClientAgentJob(jobArgs shared.ProcessJobArgs, tasksService *TasksSer
 	}
 
 	if responseData.ReplyType == "agent_plan" {
-		// Handle agent_plan reply type
-		// Parse the plans from the content
-		plans := responseData.Content
-
-		// Sort plans by step number
-		sort.Slice(plans, func(i, j int) bool {
-			stepI := plans[i].Step
-			stepJ := plans[j].Step
-			return stepI < stepJ
-		})
-		var tasksWithDependencies, tasksWithoutDependencies []shared.IAgentTask
-
-		for index, plan := range plans {
-			if index == 0 || len(plan.Dependencies) > 0 {
-				tasksWithDependencies = append(tasksWithDependencies, plan)
-			} else {
-				tasksWithoutDependencies = append(tasksWithoutDependencies, plan)
-			}
-		}
-		// log tasksWithDependencies json
-		tasksWithDependenciesJSON, _ := json.Marshal(tasksWithDependencies)
-		log.Printf("Tasks with dependencies: %s", string(tasksWithDependenciesJSON))
-
-		// log tasksWithoutDependencies json
-		tasksWithoutDependenciesJSON, _ := json.Marshal(tasksWithoutDependencies)
-		log.Printf("Tasks without dependencies: %s", string(tasksWithoutDependenciesJSON))
-
-		var snapshotStepResults []map[string]interface{}
-
-		// Execute tasks with dependencies sequentially
-		if len(tasksWithDependencies) > 0 {
-			for _, step := range tasksWithDependencies {
-				stepInt := step.Step
-
-				// Filter previous results for dependencies
-				var filteredPrevResults []map[string]interface{}
-				if len(step.Dependencies) > 0 {
-					for _, result := range snapshotStepResults {
-						for _, dep := range step.Dependencies {
-							if resultTaskID, ok := result["taskId"].(string); ok && dep == resultTaskID {
-								filteredPrevResults = append(filteredPrevResults, result)
-								break
-							}
-						}
-					}
-				}
-
-				// Prepare task with previous results
-				taskStr := step.Task
-				if len(filteredPrevResults) > 0 {
-					prevResultsJSON, _ := json.Marshal(filteredPrevResults)
-					taskStr += fmt.Sprintf("\nPrevious results: %s", string(prevResultsJSON))
-				}
-
-				response, err := executeAIAgent(step.AgentAddress+"/messages", taskStr)
-				if err != nil {
-					log.Printf("Failed to process AI agent job: %v", err)
-					continue
-				}
-
-				result := map[string]interface{}{
-					"agentName": step.AgentName,
-					"taskId":    step.TaskID,
-					"content":   response,
-				}
-
-				snapshotStepResults = append(snapshotStepResults, result)
-				log.Printf("Completed task with dependencies: %s (step %d)", step.TaskID, stepInt)
-			}
-		}
-
-		// Execute tasks without dependencies in parallel
-		if len(tasksWithoutDependencies) > 0 {
-			// Use channels to collect results from parallel execution
-			resultsChan := make(chan map[string]interface{}, len(tasksWithoutDependencies))
-			errorsChan := make(chan error, len(tasksWithoutDependencies))
-
-			// Execute tasks in parallel using goroutines
-			for _, step := range tasksWithoutDependencies {
-				go func(s shared.IAgentTask) {
-					response, err := executeAIAgent(s.AgentAddress+"/messages", s.Task)
-					if err != nil {
-						log.Printf("Failed to process AI agent job: %v", err)
-						errorsChan <- err
-						return
-					}
-
-					result := map[string]interface{}{
-						"agentName": s.AgentName,
-						"taskId":    s.TaskID,
-						"content":   response,
-					}
-
-					resultsChan <- result
-				}(step)
-			}
-
-			// Collect results from parallel execution
-			completedCount := 0
-			for completedCount < len(tasksWithoutDependencies) {
-				select {
-				case result := <-resultsChan:
-					snapshotStepResults = append(snapshotStepResults, result)
-					if stepNum, ok := result["step"].(int); ok {
-						log.Printf("Completed parallel task: %s (step %d)", result["taskId"], stepNum)
-					}
-					completedCount++
-				case err := <-errorsChan:
-					log.Printf("Error in parallel task execution: %v", err)
-					completedCount++
-				}
-			}
-		}
-
-		// // Return final result
-		if len(snapshotStepResults) > 0 {
-			return snapshotStepResults, nil
-		}
-
-		return nil, fmt.Errorf("no final result found")
+		return policy.result(runAgentPlan(ctx, responseData.Content, clientAgentData.MaxParallelSteps, policy, nil,
+			newPlanStepRecorder(tasksService, jobArgs.TaskID, jobArgs.Attempt)))
 	}
 	return "No Result Found", nil
 }
 
 // ===== AIAgentJob =====
 
-func executeAIAgent(agentURL string, message string) (interface{}, error) {
+// executeAIAgent runs a message as the A2A task agentTaskID of an agent, streaming when the agent
+// supports it. onPartial, when set, receives the output produced so far.
+func executeAIAgent(ctx context.Context, agentURL string, agentTaskID string, message string, onPartial func(partial string)) (interface{}, error) {
 	client := shared.NewAIAgentClient()
 
-	taskID := uuid.New().String()
-	completedTask, err := client.SendMessageAndWaitForCompletion(agentURL, taskID, message)
+	completedTask, err := client.SendMessageAndStream(ctx, agentURL, agentTaskID, message, func(task *shared.Task) error {
+		if onPartial != nil {
+			if partial := shared.ExtractFinalResponse(task); partial != "" {
+				onPartial(partial)
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -350,7 +635,24 @@ func executeAIAgent(agentURL string, message string) (interface{}, error) {
 	return finalResponse, nil
 }
 
-func processAIAgentJob(jobArgs shared.ProcessJobArgs, tasksService *TasksService) (interface{}, error) {
+// partialResultInterval throttles how often streamed output is written to the task row
+const partialResultInterval = time.Second
+
+// newPartialResultWriter returns a callback that stores streamed output on the running task
+func newPartialResultWriter(tasksService *TasksService, taskID uuid.UUID) func(partial string) {
+	var lastWrite time.Time
+	return func(partial string) {
+		if time.Since(lastWrite) < partialResultInterval {
+			return
+		}
+		lastWrite = time.Now()
+		if err := tasksService.UpdateTaskPartialResult(taskID, partial); err != nil {
+			log.Printf("Failed to store partial result for task %s: %v", taskID, err)
+		}
+	}
+}
+
+func processAIAgentJob(ctx context.Context, jobArgs shared.ProcessJobArgs, tasksService *TasksService) (interface{}, error) {
 	payload := models.Payload{}
 	if err := json.Unmarshal([]byte(jobArgs.Payload), &payload); err != nil {
 		return nil, err
@@ -367,7 +669,14 @@ func processAIAgentJob(jobArgs shared.ProcessJobArgs, tasksService *TasksService
 		return nil, err
 	}
 
-	response, err := executeAIAgent(agentData.URL+"/messages", payload.Prompt)
+	// The A2A task is recorded first so the task can be cancelled on the agent through the API
+	agentURL := agentData.URL + "/messages"
+	agentTaskID := uuid.New().String()
+	if err := tasksService.SetTaskAgent(jobArgs.TaskID, agentURL, agentTaskID); err != nil {
+		log.Printf("Failed to record agent task of task %s: %v", jobArgs.TaskID, err)
+	}
+
+	response, err := executeAIAgent(ctx, agentURL, agentTaskID, payload.Prompt, newPartialResultWriter(tasksService, jobArgs.TaskID))
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    []jsonPathStep
+		wantErr string
+	}{
+		{path: "$", want: []jsonPathStep{}},
+		{path: "$.data.id", want: []jsonPathStep{{key: "data"}, {key: "id"}}},
+		{path: "$['a.b']", want: []jsonPathStep{{key: "a.b"}}},
+		{path: `$["a b"].c`, want: []jsonPathStep{{key: "a b"}, {key: "c"}}},
+		{path: "$.items[0]", want: []jsonPathStep{{key: "items"}, {index: 0, isIndex: true}}},
+		{path: "$.items[-1]", want: []jsonPathStep{{key: "items"}, {index: -1, isIndex: true}}},
+		{path: "$.items[*].name", want: []jsonPathStep{{key: "items"}, {wildcard: true}, {key: "name"}}},
+		{path: "$.*", want: []jsonPathStep{{wildcard: true}}},
+		{path: "data.id", wantErr: "must start with $"},
+		{path: "$.items[0", wantErr: "unclosed ["},
+		{path: "$.items[x]", wantErr: "invalid index [x]"},
+		{path: "$..id", wantErr: "empty member name"},
+		{path: "$id", wantErr: "unexpected"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			steps, err := parseJSONPath(tt.path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseJSONPath error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseJSONPath: %v", err)
+			}
+			if !reflect.DeepEqual(steps, tt.want) {
+				t.Errorf("parseJSONPath = %+v, want %+v", steps, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvalJSONPath(t *testing.T) {
+	var doc interface{}
+	document := `{
+		"data": {"id": 42, "a.b": "dotted"},
+		"items": [{"name": "first"}, {"name": "second"}, {"id": 3}],
+		"scores": {"zeta": 3, "alpha": 1, "mid": 2, "beta": 4}
+	}`
+	if err := json.Unmarshal([]byte(document), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path string
+		want interface{}
+	}{
+		{"$.data.id", 42.0},
+		{"$['data']['a.b']", "dotted"},
+		{"$.data.a.b", nil},
+		{"$.items[0].name", "first"},
+		{"$.items[-1].id", 3.0},
+		{"$.items[-4]", nil},
+		{"$.items[3]", nil},
+		{"$.items[*].name", []interface{}{"first", "second"}},
+		{"$.items.*.name", []interface{}{"first", "second"}},
+		{"$.scores.*", []interface{}{1.0, 4.0, 2.0, 3.0}},
+		{"$.scores[*]", []interface{}{1.0, 4.0, 2.0, 3.0}},
+		{"$.missing[*]", []interface{}{}},
+		{"$.data[0]", nil},
+		{"$.items.name", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			// Run repeatedly so an order depending on map iteration shows up
+			for range 20 {
+				got, err := evalJSONPath(doc, tt.path)
+				if err != nil {
+					t.Fatalf("evalJSONPath: %v", err)
+				}
+				if !reflect.DeepEqual(got, tt.want) {
+					t.Fatalf("evalJSONPath = %#v, want %#v", got, tt.want)
+				}
+			}
+		})
+	}
+}
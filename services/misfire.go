@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"gin-gorm-river-app/models"
+	"log"
+	"time"
+)
+
+// Misfire policy defaults and limits
+const (
+	DefaultMisfireLimit = 10
+	MaxMisfireLimit     = 100
+)
+
+// misfireThreshold is how late a run may be before it counts as missed. Runs due more
+// recently are left to River, which runs them as soon as it starts.
+const misfireThreshold = time.Minute
+
+func validateMisfirePolicy(policy models.MisfirePolicy, limit int) error {
+	switch policy {
+	case "", models.MisfirePolicySkip, models.MisfirePolicyRunOnce, models.MisfirePolicyRunAllMissed:
+	default:
+		return fmt.Errorf("misfire_policy must be one of skip, run_once, run_all_missed")
+	}
+	if limit < 0 || limit > MaxMisfireLimit {
+		return fmt.Errorf("misfire_limit must be between 0 and %d", MaxMisfireLimit)
+	}
+	return nil
+}
+
+// misfireLimit returns the number of missed runs run_all_missed executes
+func misfireLimit(job *models.Jobs) int {
+	if job.MisfireLimit <= 0 {
+		return DefaultMisfireLimit
+	}
+	return min(job.MisfireLimit, MaxMisfireLimit)
+}
+
+// missedRunTimes returns the runs of a job due between its NextRunAt and now, at most limit of them.
+// Runs are only missed when NextRunAt, delayed by the job's jitter, is older than misfireThreshold.
+func (s *JobService) missedRunTimes(ctx context.Context, job *models.Jobs, now time.Time, limit int) ([]time.Time, error) {
+	if job.NextRunAt == nil {
+		return nil, nil
+	}
+	jitter, err := jobJitterOffset(s.db, job)
+	if err != nil {
+		return nil, err
+	}
+	if !job.NextRunAt.Add(jitter).Before(now.Add(-misfireThreshold)) {
+		return nil, nil
+	}
+
+	if job.Type == models.JobTypeScheduled {
+		// A one-shot job that ran normally keeps its past NextRunAt
+		var count int64
+		err := s.db.GORM.WithContext(ctx).Model(&models.Tasks{}).
+			Where("job_id = ? AND \"trigger\" IN ? AND is_deleted = false", job.ID, []models.TaskTrigger{models.TaskTriggerSchedule, models.TaskTriggerMisfire}).
+			Count(&count).Error
+		if err != nil {
+			return nil, fmt.Errorf("failed to count tasks of job %s: %w", job.ID, err)
+		}
+		if count > 0 {
+			return nil, nil
+		}
+	}
+
+	calendars, err := loadJobCalendars(s.db, job)
+	if err != nil {
+		return nil, err
+	}
+	runs, _, err := nextRunTimes(job, job.NextRunAt.Add(-time.Nanosecond), limit, calendars)
+	if err != nil {
+		return nil, err
+	}
+	missed := runs[:0]
+	for _, run := range runs {
+		if run.After(now) {
+			break
+		}
+		missed = append(missed, run)
+	}
+	return missed, nil
+}
+
+// RecoverMissedRuns applies the job's misfire policy to the runs it missed while the worker was
+// down, then schedules its next run. Recovered executions are enqueued with the misfire trigger;
+// the skip policy records a skipped misfire task instead. It reports whether the job missed runs.
+//
+// It must run before the River client starts: the stale River job of the missed run is removed
+// so River does not run it late on top of the policy.
+func (s *JobService) RecoverMissedRuns(ctx context.Context, job *models.Jobs, now time.Time) (bool, error) {
+	limit := 1
+	if job.MisfirePolicy == models.MisfirePolicyRunAllMissed {
+		limit = misfireLimit(job)
+	}
+	missed, err := s.missedRunTimes(ctx, job, now, limit)
+	if err != nil {
+		return false, err
+	}
+	if len(missed) == 0 {
+		return false, nil
+	}
+	log.Printf("Job %s missed runs since %s, applying misfire policy %s", job.ID, missed[0].Format(time.RFC3339), job.MisfirePolicy)
+
+	// The stale run is replaced by the recovered ones in one transaction, so a failure loses neither
+	riverClient := GetRiverClientInstance(s.db)
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+	if err := riverClient.DeleteScheduledRunsTx(ctx, tx, job.ID); err != nil {
+		return false, err
+	}
+	enqueue := job.MisfirePolicy == models.MisfirePolicyRunOnce || job.MisfirePolicy == models.MisfirePolicyRunAllMissed
+	if enqueue {
+		for _, occurrence := range missed {
+			if _, err := riverClient.EnqueueMisfireRunTx(ctx, tx, job, occurrence); err != nil {
+				return false, err
+			}
+		}
+	}
+	// The one-shot run of a scheduled job is handled by the policy, it has no next run
+	if job.Type == models.JobTypeScheduled {
+		if _, err := tx.Exec(ctx, `UPDATE jobs SET next_run_at = NULL, updated_at = $1 WHERE id = $2`, now, job.ID); err != nil {
+			return false, err
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return false, err
+	}
+	if job.Type == models.JobTypeScheduled {
+		job.NextRunAt = nil
+	}
+
+	if enqueue {
+		log.Printf("Enqueued %d missed runs of job %s", len(missed), job.ID)
+	} else {
+		tasksService := NewTasksService(s.db)
+		taskID, err := tasksService.CreateTask(&CreateTaskRequest{
+			JobID:        job.ID,
+			Payload:      job.Payload,
+			Trigger:      models.TaskTriggerMisfire,
+			ScheduledFor: &missed[0],
+		})
+		if err != nil {
+			return false, err
+		}
+		reason := fmt.Sprintf("Skipped: run due at %s was missed while the worker was down", missed[0].Format(time.RFC3339))
+		if err := tasksService.UpdateTaskResult(taskID, reason, models.TaskStatusSkipped); err != nil {
+			return false, err
+		}
+	}
+
+	if job.Type == models.JobTypeInterval {
+		if err := s.RescheduleIntervalJob(ctx, job); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
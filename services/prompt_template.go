@@ -0,0 +1,201 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"gin-gorm-river-app/models"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// promptVariable matches a {{ name }} variable of a prompt template
+var promptVariable = regexp.MustCompile(`\{\{\s*([^{}]*?)\s*\}\}`)
+
+var promptVariableName = regexp.MustCompile(`^[a-z_]+(\.[A-Za-z0-9_\-]+)*$`)
+
+// {{{{ and }}}} write a literal {{ and }}. They are swapped for private use characters while the
+// variables are matched, so a literal {{name}} is written {{{{name}}}}.
+var (
+	hideLiteralBraces    = strings.NewReplacer("{{{{", "\uE000", "}}}}", "\uE001")
+	restoreLiteralBraces = strings.NewReplacer("\uE000", "{{", "\uE001", "}}")
+)
+
+// promptVariables are the variables available to every job. Webhook jobs also get
+// webhook.body and webhook.<path>, jobs with depends_on get the upstream.* variables.
+var promptVariables = map[string]bool{
+	"now":             true,
+	"date":            true,
+	"time":            true,
+	"weekday":         true,
+	"run_number":      true,
+	"job.id":          true,
+	"job.name":        true,
+	"workspace_id":    true,
+	"previous.result": true,
+	"previous.status": true,
+}
+
+var upstreamVariables = map[string]bool{
+	"upstream.job_id": true,
+	"upstream.result": true,
+	"upstream.status": true,
+}
+
+// validatePromptTemplate reports the first malformed or unknown variable of a prompt
+func validatePromptTemplate(prompt string, jobType models.JobType, hasDependencies bool) error {
+	prompt = hideLiteralBraces.Replace(prompt)
+	for _, match := range promptVariable.FindAllStringSubmatch(prompt, -1) {
+		name := match[1]
+		switch {
+		case !promptVariableName.MatchString(name):
+			return fmt.Errorf("invalid variable %q", match[0])
+		case promptVariables[name]:
+		case upstreamVariables[name]:
+			if !hasDependencies {
+				return fmt.Errorf("variable %s is only available to jobs with depends_on", name)
+			}
+		case strings.HasPrefix(name, "webhook."):
+			if jobType != models.JobTypeWebhook {
+				return fmt.Errorf("variable %s is only available to webhook jobs", name)
+			}
+		default:
+			return fmt.Errorf("unknown variable %s, write {{{{ and }}}} for literal braces", name)
+		}
+	}
+
+	rest := promptVariable.ReplaceAllString(prompt, "")
+	if strings.Contains(rest, "{{") || strings.Contains(rest, "}}") {
+		return fmt.Errorf("unbalanced {{ or }}, write {{{{ and }}}} for literal braces")
+	}
+	return nil
+}
+
+// promptContext holds the values of the variables of one run
+type promptContext struct {
+	job         *models.Jobs
+	now         time.Time
+	runNumber   int
+	previous    *models.Tasks // latest finished task of the job, nil on the first run
+	upstream    *models.Tasks // task that triggered a dependency run
+	upstreamErr string        // error of the upstream task when it failed without result
+	webhookBody string
+}
+
+// usesUpstream reports whether a prompt references the upstream.* variables
+func usesUpstream(prompt string) bool {
+	for _, match := range promptVariable.FindAllStringSubmatch(hideLiteralBraces.Replace(prompt), -1) {
+		if upstreamVariables[match[1]] {
+			return true
+		}
+	}
+	return false
+}
+
+// renderPrompt replaces the variables of a prompt. Variables without a value in this run,
+// such as previous.result on the first run, render empty; unknown ones are left as is.
+func renderPrompt(prompt string, pc *promptContext) string {
+	return renderTemplate(prompt, pc, nil)
+}
+
+// renderTemplate is renderPrompt passing each value through escape, when set
+func renderTemplate(template string, pc *promptContext, escape func(value string) string) string {
+	var webhookFields interface{}
+	if pc.webhookBody != "" {
+		_ = json.Unmarshal([]byte(pc.webhookBody), &webhookFields)
+	}
+
+	rendered := promptVariable.ReplaceAllStringFunc(hideLiteralBraces.Replace(template), func(match string) string {
+		name := promptVariable.FindStringSubmatch(match)[1]
+		value, ok := pc.value(name, webhookFields)
+		if !ok {
+			return match
+		}
+		if escape != nil {
+			return escape(value)
+		}
+		return value
+	})
+	return restoreLiteralBraces.Replace(rendered)
+}
+
+// escapeJSONString encodes a value as the content of a JSON string, without the quotes
+func escapeJSONString(value string) string {
+	encoded, _ := json.Marshal(value)
+	return string(encoded[1 : len(encoded)-1])
+}
+
+func (pc *promptContext) value(name string, webhookFields interface{}) (string, bool) {
+	loc, err := loadTimezone(pc.job.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	now := pc.now.In(loc)
+
+	switch name {
+	case "now":
+		return now.Format(time.RFC3339), true
+	case "date":
+		return now.Format("2006-01-02"), true
+	case "time":
+		return now.Format("15:04"), true
+	case "weekday":
+		return now.Weekday().String(), true
+	case "run_number":
+		return strconv.Itoa(pc.runNumber), true
+	case "job.id":
+		return pc.job.ID.String(), true
+	case "job.name":
+		return pc.job.Name, true
+	case "workspace_id":
+		return pc.job.WorkspaceID.String(), true
+	case "previous.result", "previous.status":
+		if pc.previous == nil {
+			return "", true
+		}
+		if name == "previous.status" {
+			return string(pc.previous.Status), true
+		}
+		return pc.previous.Result, true
+	case "upstream.job_id", "upstream.result", "upstream.status":
+		if pc.upstream == nil {
+			return "", true
+		}
+		switch name {
+		case "upstream.job_id":
+			return pc.upstream.JobID.String(), true
+		case "upstream.status":
+			return string(pc.upstream.Status), true
+		}
+		if pc.upstream.Result == "" {
+			return pc.upstreamErr, true
+		}
+		return pc.upstream.Result, true
+	}
+
+	path, ok := strings.CutPrefix(name, "webhook.")
+	if !ok {
+		return "", false
+	}
+	if path == "body" {
+		return pc.webhookBody, true
+	}
+	value := webhookFields
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return "", true
+		}
+		value = object[key]
+	}
+	switch v := value.(type) {
+	case nil:
+		return "", true
+	case string:
+		return v, true
+	default:
+		encoded, _ := json.Marshal(v)
+		return string(encoded), true
+	}
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gin-gorm-river-app/models"
+	"gin-gorm-river-app/shared"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+)
+
+// Retry policy defaults and limits
+const (
+	maxRetryAttempts       = 10
+	defaultRetryDelay      = 30 * time.Second
+	defaultRetryMaxDelay   = time.Hour
+	maxRetryDelay          = 24 * time.Hour
+	defaultRetryBackoff    = models.BackoffExponential
+	defaultRetryMaxAttempt = 1
+)
+
+var defaultRetryOn = []models.ErrorClass{
+	models.ErrorClassNetwork,
+	models.ErrorClassTimeout,
+	models.ErrorClassServerError,
+}
+
+// parseRetryPolicy decodes the retry_policy JSON of a job, nil means no retries
+func parseRetryPolicy(raw *string) (*models.RetryPolicy, error) {
+	if raw == nil || *raw == "" {
+		return nil, nil
+	}
+
+	var policy models.RetryPolicy
+	if err := json.Unmarshal([]byte(*raw), &policy); err != nil {
+		return nil, fmt.Errorf("invalid retry_policy: %w", err)
+	}
+	if err := validateRetryPolicy(&policy); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+func validateRetryPolicy(policy *models.RetryPolicy) error {
+	if policy.MaxAttempts < 1 || policy.MaxAttempts > maxRetryAttempts {
+		return fmt.Errorf("retry_policy.max_attempts must be between 1 and %d", maxRetryAttempts)
+	}
+	switch policy.Backoff {
+	case "", models.BackoffFixed, models.BackoffExponential:
+	default:
+		return fmt.Errorf("retry_policy.backoff must be fixed or exponential")
+	}
+	if policy.DelaySeconds < 0 || time.Duration(policy.DelaySeconds)*time.Second > maxRetryDelay {
+		return fmt.Errorf("retry_policy.delay_seconds must be between 0 and %d", int(maxRetryDelay.Seconds()))
+	}
+	if policy.MaxDelaySeconds < 0 || time.Duration(policy.MaxDelaySeconds)*time.Second > maxRetryDelay {
+		return fmt.Errorf("retry_policy.max_delay_seconds must be between 0 and %d", int(maxRetryDelay.Seconds()))
+	}
+	for _, class := range policy.RetryOn {
+		switch class {
+		case models.ErrorClassNetwork, models.ErrorClassTimeout, models.ErrorClassServerError,
+			models.ErrorClassClientError, models.ErrorClassAgentError, models.ErrorClassOther:
+		default:
+			return fmt.Errorf("retry_policy.retry_on contains unknown error class %q", class)
+		}
+	}
+	return nil
+}
+
+// retryMaxAttempts returns the River MaxAttempts for a policy
+func retryMaxAttempts(policy *models.RetryPolicy) int {
+	if policy == nil {
+		return defaultRetryMaxAttempt
+	}
+	return policy.MaxAttempts
+}
+
+// shouldRetry reports whether a failure of the given class is retried by the policy
+func shouldRetry(policy *models.RetryPolicy, class models.ErrorClass) bool {
+	if policy == nil || policy.MaxAttempts <= 1 {
+		return false
+	}
+	retryOn := policy.RetryOn
+	if len(retryOn) == 0 {
+		retryOn = defaultRetryOn
+	}
+	return slices.Contains(retryOn, class)
+}
+
+// retryDelay returns the wait after the given failed attempt (1-based)
+func retryDelay(policy *models.RetryPolicy, attempt int) time.Duration {
+	delay := defaultRetryDelay
+	if policy.DelaySeconds > 0 {
+		delay = time.Duration(policy.DelaySeconds) * time.Second
+	}
+
+	backoff := policy.Backoff
+	if backoff == "" {
+		backoff = defaultRetryBackoff
+	}
+	if backoff == models.BackoffFixed {
+		return delay
+	}
+
+	maxDelay := defaultRetryMaxDelay
+	if policy.MaxDelaySeconds > 0 {
+		maxDelay = time.Duration(policy.MaxDelaySeconds) * time.Second
+	}
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxDelay)
+}
+
+// classifyError maps a run failure to an ErrorClass.
+// Cancellations are not classified: a cancelled run is never retried.
+func classifyError(err error) models.ErrorClass {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return ""
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return models.ErrorClassTimeout
+	}
+
+	var statusErr *shared.HTTPStatusError
+	if errors.As(err, &statusErr) {
+		if statusErr.StatusCode >= 500 || statusErr.StatusCode == http.StatusTooManyRequests {
+			return models.ErrorClassServerError
+		}
+		return models.ErrorClassClientError
+	}
+
+	var rpcErr *shared.JSONRPCError
+	if errors.As(err, &rpcErr) {
+		return models.ErrorClassAgentError
+	}
+
+	// A refused http_request target fails the same way on every attempt
+	if errors.Is(err, errHTTPRequestDenied) {
+		return models.ErrorClassClientError
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return models.ErrorClassTimeout
+		}
+		return models.ErrorClassNetwork
+	}
+
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return models.ErrorClassNetwork
+	}
+
+	return models.ErrorClassOther
+}
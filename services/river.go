@@ -12,6 +12,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/riverqueue/river"
 	"github.com/riverqueue/river/riverdriver/riverpgxv5"
@@ -21,6 +22,7 @@ var lock = &sync.Mutex{}
 
 type RiverClient struct {
 	Client *river.Client[pgx.Tx]
+	db     *config.Database
 }
 
 var riverClientInstance *RiverClient
@@ -64,31 +66,142 @@ func GetRiverClientInstance(db *config.Database) *RiverClient {
 
 	riverClientInstance = &RiverClient{
 		Client: newClient,
+		db:     db,
 	}
 	return riverClientInstance
 }
 
 func (s *RiverClient) ScheduleJobInRiver(ctx context.Context, job *models.Jobs) error {
-	if job.NextRunAt == nil {
-		return fmt.Errorf("next run time not calculated")
+	args, opts, err := s.scheduleInsertParams(job)
+	if err != nil {
+		return err
+	}
+
+	createdJob, err := s.Client.Insert(ctx, args, opts)
+	if err != nil {
+		return err
+	}
+	job.RiverJobID = createdJob.Job.ID
+	job.UpdatedAt = time.Now()
+	return nil
+}
+
+// EnqueueJobRun inserts an immediate, out-of-schedule execution of a job.
+// It is not unique so it never collides with the scheduled run.
+func (s *RiverClient) EnqueueJobRun(ctx context.Context, job *models.Jobs, trigger models.TaskTrigger) (int64, error) {
+	args, opts, err := runInsertParams(job, trigger)
+	if err != nil {
+		return 0, err
 	}
+	return s.insertRun(ctx, args, opts)
+}
 
-	scheduledAt := *job.NextRunAt
+// EnqueueDependentRun inserts an execution of a job triggered by a finished task of one of its
+// upstream jobs. The worker passes the result of that task to the run's prompt.
+func (s *RiverClient) EnqueueDependentRun(ctx context.Context, job *models.Jobs, upstreamTaskID uuid.UUID) (int64, error) {
+	args, opts, err := runInsertParams(job, models.TaskTriggerDependency)
+	if err != nil {
+		return 0, err
+	}
+	args.UpstreamTaskID = &upstreamTaskID
+	return s.insertRun(ctx, args, opts)
+}
+
+// EnqueueWebhookRun inserts an execution of a webhook job requested through its hook URL.
+// The worker merges the request body into the run's prompt.
+func (s *RiverClient) EnqueueWebhookRun(ctx context.Context, job *models.Jobs, request *models.WebhookRequest, body string) (int64, error) {
+	args, opts, err := runInsertParams(job, models.TaskTriggerWebhook)
+	if err != nil {
+		return 0, err
+	}
+	args.Webhook = request
+	args.WebhookBody = body
+	return s.insertRun(ctx, args, opts)
+}
+
+// EnqueueRetryRun inserts a run that executes again the payload rendered for a task, as a new task
+// linked to it
+func (s *RiverClient) EnqueueRetryRun(ctx context.Context, job *models.Jobs, task *models.Tasks) (int64, error) {
+	args, opts, err := runInsertParams(job, models.TaskTriggerRetry)
+	if err != nil {
+		return 0, err
+	}
+	args.Payload = task.Payload
+	args.UpstreamTaskID = task.UpstreamTaskID
+	args.Webhook = task.Request
+	args.RetryOfTaskID = &task.ID
+	return s.insertRun(ctx, args, opts)
+}
+
+// EnqueueMisfireRunTx inserts, inside a caller-managed transaction, a run recovering the occurrence
+// of a job due at scheduledFor
+func (s *RiverClient) EnqueueMisfireRunTx(ctx context.Context, tx pgx.Tx, job *models.Jobs, scheduledFor time.Time) (int64, error) {
+	args, opts, err := runInsertParams(job, models.TaskTriggerMisfire)
+	if err != nil {
+		return 0, err
+	}
+	args.ScheduledFor = &scheduledFor
+	createdJob, err := s.Client.InsertTx(ctx, tx, args, opts)
+	if err != nil {
+		return 0, err
+	}
+	return createdJob.Job.ID, nil
+}
+
+// EnqueueResumeRunTx inserts, inside a caller-managed transaction, a run that resumes the agent
+// plan of a task. It runs the payload rendered for the task rather than the job's current one.
+func (s *RiverClient) EnqueueResumeRunTx(ctx context.Context, tx pgx.Tx, job *models.Jobs, task *models.Tasks) (int64, error) {
+	args, opts, err := runInsertParams(job, task.Trigger)
+	if err != nil {
+		return 0, err
+	}
+	args.Payload = task.Payload
+	args.UpstreamTaskID = task.UpstreamTaskID
+	args.ResumeTaskID = &task.ID
+	createdJob, err := s.Client.InsertTx(ctx, tx, args, opts)
+	if err != nil {
+		return 0, err
+	}
+	return createdJob.Job.ID, nil
+}
+
+// runInsertParams builds the River job of an immediate run of a job
+func runInsertParams(job *models.Jobs, trigger models.TaskTrigger) (shared.IntervalJobArgs, *river.InsertOpts, error) {
+	retryPolicy, err := parseRetryPolicy(job.RetryPolicy)
+	if err != nil {
+		return shared.IntervalJobArgs{}, nil, err
+	}
 
 	args := shared.IntervalJobArgs{
 		JobID:       job.ID,
 		UserID:      job.UserID,
 		WorkspaceID: job.WorkspaceID,
 		Payload:     job.Payload,
+		Trigger:     string(trigger),
+		RetryPolicy: retryPolicy,
 	}
-	createdJob, err := s.Client.Insert(ctx, args, &river.InsertOpts{
-		ScheduledAt: scheduledAt,
-		MaxAttempts: 1,
-		UniqueOpts: river.UniqueOpts{
-			ByArgs:   true,
-			ByPeriod: 4 * time.Minute, // min interval is 5 minutes => 4 minutes
-		},
-	})
+	opts := &river.InsertOpts{
+		MaxAttempts: retryMaxAttempts(retryPolicy),
+	}
+	return args, opts, nil
+}
+
+func (s *RiverClient) insertRun(ctx context.Context, args shared.IntervalJobArgs, opts *river.InsertOpts) (int64, error) {
+	createdJob, err := s.Client.Insert(ctx, args, opts)
+	if err != nil {
+		return 0, err
+	}
+	return createdJob.Job.ID, nil
+}
+
+// ScheduleJobInRiverTx is ScheduleJobInRiver inside a caller-managed transaction
+func (s *RiverClient) ScheduleJobInRiverTx(ctx context.Context, tx pgx.Tx, job *models.Jobs) error {
+	args, opts, err := s.scheduleInsertParams(job)
+	if err != nil {
+		return err
+	}
+
+	createdJob, err := s.Client.InsertTx(ctx, tx, args, opts)
 	if err != nil {
 		return err
 	}
@@ -96,3 +209,54 @@ func (s *RiverClient) ScheduleJobInRiver(ctx context.Context, job *models.Jobs)
 	job.UpdatedAt = time.Now()
 	return nil
 }
+
+// DeleteScheduledRunsTx removes the not yet running scheduled River jobs of a job.
+// Manual and misfire runs, scheduled runs waiting for a retry and task resumes, which keep the
+// trigger of the task they resume, are kept.
+func (s *RiverClient) DeleteScheduledRunsTx(ctx context.Context, tx pgx.Tx, jobID uuid.UUID) error {
+	query := `DELETE FROM river_job WHERE args ->> 'job_id' = $1 AND COALESCE(args ->> 'trigger', '') IN ('', 'schedule') AND args ->> 'resume_task_id' IS NULL
+		AND state IN ('available', 'scheduled')`
+	if _, err := tx.Exec(ctx, query, jobID.String()); err != nil {
+		return fmt.Errorf("failed to delete scheduled River jobs: %w", err)
+	}
+	return nil
+}
+
+// riverUniquePeriod is the window in which River deduplicates scheduled runs of a job.
+// It stays below MinIntervalPeriod so two legitimate runs never share a window.
+const riverUniquePeriod = 4 * time.Minute
+
+// scheduleInsertParams builds the River job of the next run, delayed by the job's jitter
+func (s *RiverClient) scheduleInsertParams(job *models.Jobs) (shared.IntervalJobArgs, *river.InsertOpts, error) {
+	if job.NextRunAt == nil {
+		return shared.IntervalJobArgs{}, nil, fmt.Errorf("next run time not calculated")
+	}
+
+	jitter, err := jobJitterOffset(s.db, job)
+	if err != nil {
+		return shared.IntervalJobArgs{}, nil, err
+	}
+
+	retryPolicy, err := parseRetryPolicy(job.RetryPolicy)
+	if err != nil {
+		return shared.IntervalJobArgs{}, nil, err
+	}
+
+	args := shared.IntervalJobArgs{
+		JobID:        job.ID,
+		UserID:       job.UserID,
+		WorkspaceID:  job.WorkspaceID,
+		Payload:      job.Payload,
+		RetryPolicy:  retryPolicy,
+		ScheduledFor: job.NextRunAt,
+	}
+	opts := &river.InsertOpts{
+		ScheduledAt: job.NextRunAt.Add(jitter),
+		MaxAttempts: retryMaxAttempts(retryPolicy),
+		UniqueOpts: river.UniqueOpts{
+			ByArgs:   true,
+			ByPeriod: riverUniquePeriod,
+		},
+	}
+	return args, opts, nil
+}
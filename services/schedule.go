@@ -0,0 +1,440 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"gin-gorm-river-app/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// DefaultJobTimezone is used for jobs created without a timezone
+const DefaultJobTimezone = "UTC"
+
+// MaxNextRunsPreview bounds the number of run times returned by a schedule preview
+const MaxNextRunsPreview = 50
+
+// loadTimezone resolves an IANA timezone name, an empty name means UTC.
+// "Local" is rejected: it would make schedules depend on the server's timezone.
+func loadTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		return time.UTC, nil
+	}
+	if name == "Local" {
+		return nil, fmt.Errorf("timezone must be an IANA name such as Europe/Paris")
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q", name)
+	}
+	return loc, nil
+}
+
+// parseCronSchedule parses a standard 5-field cron expression evaluated in loc.
+//
+// DST transitions are handled like cronie does:
+//   - schedules with a fixed hour field fire on wall-clock time: a time skipped by the
+//     spring-forward transition fires at the first instant after the gap, and a time
+//     repeated by the fall-back transition fires once, at its first occurrence;
+//   - schedules whose hour field is "*" fire on elapsed time and are not adjusted.
+func parseCronSchedule(expr string, loc *time.Location) (cron.Schedule, error) {
+	trimmed := strings.TrimSpace(expr)
+	if strings.HasPrefix(trimmed, "CRON_TZ=") || strings.HasPrefix(trimmed, "TZ=") {
+		return nil, fmt.Errorf("set the job timezone instead of a CRON_TZ prefix")
+	}
+
+	schedule, err := cron.ParseStandard(trimmed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cron expression %q: %w", expr, err)
+	}
+
+	spec, ok := schedule.(*cron.SpecSchedule)
+	if !ok {
+		// @every descriptors are durations, independent of the timezone
+		return schedule, nil
+	}
+
+	const allHours = 1<<24 - 1
+	if spec.Hour&allHours == allHours {
+		spec.Location = loc
+		return spec, nil
+	}
+	spec.Location = time.UTC
+	return &wallClockSchedule{spec: spec, loc: loc}, nil
+}
+
+// wallClockSchedule evaluates a cron spec on the wall clock of loc
+type wallClockSchedule struct {
+	spec *cron.SpecSchedule // Evaluated in UTC, which has no DST
+	loc  *time.Location
+}
+
+func (s *wallClockSchedule) Next(t time.Time) time.Time {
+	wall := toWallClock(t.In(s.loc))
+	for {
+		next := s.spec.Next(wall)
+		if next.IsZero() {
+			return next
+		}
+		at, _ := wallClockInstant(next, s.loc)
+		if at.After(t) {
+			return at
+		}
+		wall = next
+	}
+}
+
+// toWallClock returns the wall-clock reading of t as a UTC time
+func toWallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
+
+// wallClockInstant returns the instant at which loc shows the wall-clock time wall.
+// A repeated wall time resolves to its first occurrence. For a wall time skipped by a DST
+// gap it returns the first instant after the gap and false.
+func wallClockInstant(wall time.Time, loc *time.Location) (time.Time, bool) {
+	_, offsetBefore := wall.Add(-24 * time.Hour).In(loc).Zone()
+	_, offsetAfter := wall.Add(24 * time.Hour).In(loc).Zone()
+
+	early := wall.Add(-time.Duration(max(offsetBefore, offsetAfter)) * time.Second)
+	late := wall.Add(-time.Duration(min(offsetBefore, offsetAfter)) * time.Second)
+	for _, candidate := range []time.Time{early, late} {
+		if toWallClock(candidate.In(loc)).Equal(wall) {
+			return candidate, true
+		}
+	}
+
+	// Gap: search the transition between the two candidates
+	for late.Sub(early) > time.Second {
+		mid := early.Add(late.Sub(early) / 2)
+		if _, offset := mid.In(loc).Zone(); offset == offsetBefore {
+			early = mid
+		} else {
+			late = mid
+		}
+	}
+	return late.Truncate(time.Second), false
+}
+
+// MinIntervalPeriod is the shortest minutes/hours interval and the shortest gap between two runs
+// of a cron job. Runs closer than riverUniquePeriod would be deduplicated by River.
+const MinIntervalPeriod = 5 * time.Minute
+
+// cronGapSamples is the number of consecutive runs of a cron schedule checked against MinIntervalPeriod
+const cronGapSamples = 1000
+
+// checkCronGap returns an error when two of the next cronGapSamples runs of a cron schedule are
+// less than MinIntervalPeriod apart
+func checkCronGap(schedule cron.Schedule, from time.Time) error {
+	prev := schedule.Next(from)
+	for i := 0; i < cronGapSamples && !prev.IsZero(); i++ {
+		next := schedule.Next(prev)
+		if next.IsZero() {
+			break
+		}
+		if next.Sub(prev) < MinIntervalPeriod {
+			return fmt.Errorf("runs at %s and %s are less than %s apart", prev.Format(time.RFC3339), next.Format(time.RFC3339), MinIntervalPeriod)
+		}
+		prev = next
+	}
+	return nil
+}
+
+// jobIntervalSchedule returns the schedule of an interval job in its timezone
+func jobIntervalSchedule(job *models.Jobs) (cron.Schedule, error) {
+	intervalData, err := parseIntervalData(job.Interval)
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := loadTimezone(job.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	upgradeLegacyInterval(intervalData)
+	return newIntervalSchedule(*intervalData, job.CreatedAt, loc)
+}
+
+// upgradeLegacyInterval reads a stored value that is not a number as a cron expression. Jobs created
+// before the cron interval type stored cron expressions under any interval_type; the setup migration
+// rewrites them, this keeps them running until it has been applied.
+func upgradeLegacyInterval(data *models.IntervalData) {
+	if data.IntervalType == models.IntervalTypeCron || data.Value == nil {
+		return
+	}
+	if _, err := strconv.Atoi(strings.TrimSpace(*data.Value)); err != nil {
+		data.IntervalType = models.IntervalTypeCron
+	}
+}
+
+// parseIntervalData decodes the interval JSON of a job
+func parseIntervalData(raw *string) (*models.IntervalData, error) {
+	if raw == nil {
+		return nil, fmt.Errorf("interval is required for interval jobs")
+	}
+	var intervalData models.IntervalData
+	if err := json.Unmarshal([]byte(*raw), &intervalData); err != nil {
+		return nil, err
+	}
+	return &intervalData, nil
+}
+
+// newIntervalSchedule builds the schedule described by IntervalData. Fixed intervals are
+// anchored to start_at, or to created (rounded down to the minute) when it is not set.
+func newIntervalSchedule(data models.IntervalData, created time.Time, loc *time.Location) (cron.Schedule, error) {
+	if data.Value == nil {
+		return nil, fmt.Errorf("value is required for interval jobs")
+	}
+	if data.IntervalType == models.IntervalTypeCron {
+		return parseCronSchedule(*data.Value, loc)
+	}
+
+	every, err := strconv.Atoi(strings.TrimSpace(*data.Value))
+	if err != nil || every < 1 {
+		return nil, fmt.Errorf("value must be a positive number of %s", data.IntervalType)
+	}
+
+	anchor := created.Truncate(time.Minute)
+	if data.StartAt != nil {
+		anchor, err = parseDateTime(*data.StartAt, loc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid start_at: %w", err)
+		}
+	}
+
+	schedule := &fixedIntervalSchedule{unit: data.IntervalType, every: every, anchor: anchor, loc: loc}
+	switch data.IntervalType {
+	case models.IntervalTypeMinutes:
+		schedule.period = time.Duration(every) * time.Minute
+	case models.IntervalTypeHours:
+		schedule.period = time.Duration(every) * time.Hour
+	case models.IntervalTypeDays, models.IntervalTypeMonths:
+		return schedule, nil
+	default:
+		return nil, fmt.Errorf("unsupported interval_type: %s", data.IntervalType)
+	}
+	if schedule.period < MinIntervalPeriod {
+		return nil, fmt.Errorf("interval must be at least %s", MinIntervalPeriod)
+	}
+	return schedule, nil
+}
+
+// fixedIntervalSchedule fires every N units from an anchor.
+// Minutes and hours are elapsed time. Days and months keep the anchor's wall-clock time in
+// loc across DST, and months falling short of the anchor's day run on their last day.
+type fixedIntervalSchedule struct {
+	unit   models.IntervalType
+	every  int
+	period time.Duration // Set for minutes and hours
+	anchor time.Time
+	loc    *time.Location
+}
+
+func (s *fixedIntervalSchedule) Next(t time.Time) time.Time {
+	if t.Before(s.anchor) {
+		return s.anchor
+	}
+	if s.period > 0 {
+		return s.anchor.Add((t.Sub(s.anchor)/s.period + 1) * s.period)
+	}
+
+	anchorWall := toWallClock(s.anchor.In(s.loc))
+	wall := toWallClock(t.In(s.loc))
+
+	// Estimate the occurrence index, then step forward to the first one after t
+	var k int
+	if s.unit == models.IntervalTypeDays {
+		k = int(wall.Sub(anchorWall).Hours()/24) / s.every
+	} else {
+		months := (wall.Year()-anchorWall.Year())*12 + int(wall.Month()-anchorWall.Month())
+		k = months / s.every
+	}
+	for k = max(k-1, 0); ; k++ {
+		var occurrence time.Time
+		if s.unit == models.IntervalTypeDays {
+			occurrence = anchorWall.AddDate(0, 0, k*s.every)
+		} else {
+			occurrence = addMonthsClamped(anchorWall, k*s.every)
+		}
+		if at, _ := wallClockInstant(occurrence, s.loc); at.After(t) {
+			return at
+		}
+	}
+}
+
+// addMonthsClamped adds months to t, moving to the last day of the month when t's day does not exist
+func addMonthsClamped(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	return first.AddDate(0, 0, min(t.Day(), lastDay)-1)
+}
+
+// parseDateTime accepts RFC 3339 timestamps with a Z or numeric offset, and local
+// timestamps without offset which are read in loc. A local time that does not exist
+// because of a DST gap is rejected; a repeated one resolves to its first occurrence.
+func parseDateTime(dateStr string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, dateStr); err == nil {
+		return t, nil
+	}
+
+	localFormats := []string{
+		"2006-01-02T15:04:05.999999999",
+		"2006-01-02T15:04",
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04",
+	}
+	for _, format := range localFormats {
+		wall, err := time.Parse(format, dateStr)
+		if err != nil {
+			continue
+		}
+		t, ok := wallClockInstant(wall, loc)
+		if !ok {
+			return time.Time{}, fmt.Errorf("%s does not exist in %s because of a DST transition", dateStr, loc)
+		}
+		return t, nil
+	}
+
+	return time.Time{}, fmt.Errorf("unsupported datetime format: %s", dateStr)
+}
+
+// SuppressedRun is an occurrence left out because one of the job's calendars blacks it out
+type SuppressedRun struct {
+	At     time.Time `json:"at"`
+	Reason string    `json:"reason"`
+}
+
+// maxSuppressedRuns bounds the suppressed occurrences returned with the runs
+const maxSuppressedRuns = 1000
+
+// runSearchHorizon bounds how far past its first blacked out occurrence the search for runs goes,
+// so calendars blacking out every occurrence cannot loop forever
+const runSearchHorizon = 366 * 24 * time.Hour
+
+// nextRunTimes returns up to count run times of a job after the given time, in the job's timezone.
+// Runs outside the starts_at/ends_at window or beyond max_runs are left out; runs blacked out by
+// calendars are left out and returned as suppressed.
+func nextRunTimes(job *models.Jobs, after time.Time, count int, calendars jobCalendars) ([]time.Time, []SuppressedRun, error) {
+	runs, suppressed, _, err := searchRunTimes(job, after, count, calendars)
+	return runs, suppressed, err
+}
+
+// searchRunTimes is nextRunTimes, also returning the last blacked out occurrence when the search
+// stopped at runSearchHorizon rather than at the end of the schedule
+func searchRunTimes(job *models.Jobs, after time.Time, count int, calendars jobCalendars) ([]time.Time, []SuppressedRun, time.Time, error) {
+	var horizonReached time.Time
+	loc, err := loadTimezone(job.Timezone)
+	if err != nil {
+		return nil, nil, horizonReached, err
+	}
+	if job.MaxRuns != nil {
+		count = min(count, *job.MaxRuns-job.RunCount)
+	}
+
+	runs := []time.Time{}
+	suppressed := []SuppressedRun{}
+	switch job.Type {
+	case models.JobTypeScheduled:
+		if job.NextRunAt != nil && job.NextRunAt.After(after) && count > 0 && withinRunWindow(job, *job.NextRunAt) {
+			if reason := calendars.suppression(*job.NextRunAt); reason != "" {
+				suppressed = append(suppressed, SuppressedRun{At: job.NextRunAt.In(loc), Reason: reason})
+			} else {
+				runs = append(runs, job.NextRunAt.In(loc))
+			}
+		}
+	case models.JobTypeInterval:
+		schedule, err := jobIntervalSchedule(job)
+		if err != nil {
+			return nil, nil, horizonReached, err
+		}
+		next := after
+		if job.StartsAt != nil && job.StartsAt.After(next) {
+			next = job.StartsAt.Add(-time.Second)
+		}
+		var firstSuppressed, lastSuppressed time.Time
+		for len(runs) < count {
+			next = schedule.Next(next)
+			if next.IsZero() || (job.EndsAt != nil && next.After(*job.EndsAt)) {
+				break
+			}
+			if !firstSuppressed.IsZero() && next.Sub(firstSuppressed) > runSearchHorizon {
+				horizonReached = lastSuppressed.In(loc)
+				break
+			}
+			if !withinRunWindow(job, next) {
+				continue
+			}
+			if reason := calendars.suppression(next); reason != "" {
+				if firstSuppressed.IsZero() {
+					firstSuppressed = next
+				}
+				lastSuppressed = next
+				if len(suppressed) < maxSuppressedRuns {
+					suppressed = append(suppressed, SuppressedRun{At: next.In(loc), Reason: reason})
+				}
+				continue
+			}
+			runs = append(runs, next.In(loc))
+		}
+	case models.JobTypeDependent, models.JobTypeWebhook:
+		// Dependent and webhook jobs run when an upstream task finishes or a hook is called, never on a schedule
+	default:
+		return nil, nil, horizonReached, fmt.Errorf("unsupported job type: %s", job.Type)
+	}
+	return runs, suppressed, horizonReached, nil
+}
+
+// withinRunWindow reports whether t is inside the job's starts_at/ends_at window
+func withinRunWindow(job *models.Jobs, t time.Time) bool {
+	if job.StartsAt != nil && t.Before(*job.StartsAt) {
+		return false
+	}
+	return job.EndsAt == nil || !t.After(*job.EndsAt)
+}
+
+// JobRunLimitReached reports whether a job has used its max_runs or passed its ends_at at time at
+func JobRunLimitReached(job *models.Jobs, at time.Time) bool {
+	if job.MaxRuns != nil && job.RunCount >= *job.MaxRuns {
+		return true
+	}
+	return job.EndsAt != nil && at.After(*job.EndsAt)
+}
+
+// parseJobTime parses an optional starts_at/ends_at value, an empty value means unset
+func parseJobTime(value *string, loc *time.Location) (*time.Time, error) {
+	if value == nil || *value == "" {
+		return nil, nil
+	}
+	t, err := parseDateTime(*value, loc)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// setRunWindow sets the starts_at/ends_at window of a job, read in the job's timezone
+func setRunWindow(job *models.Jobs, startsAt *string, endsAt *string) error {
+	loc, err := loadTimezone(job.Timezone)
+	if err != nil {
+		return err
+	}
+	if job.StartsAt, err = parseJobTime(startsAt, loc); err != nil {
+		return fmt.Errorf("invalid starts_at: %w", err)
+	}
+	if job.EndsAt, err = parseJobTime(endsAt, loc); err != nil {
+		return fmt.Errorf("invalid ends_at: %w", err)
+	}
+	return nil
+}
+
+// formatJobTime is the inverse of parseJobTime
+func formatJobTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	value := t.Format(time.RFC3339Nano)
+	return &value
+}
@@ -0,0 +1,300 @@
+package services
+
+import (
+	"gin-gorm-river-app/models"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func mustLoadLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("load %s: %v", name, err)
+	}
+	return loc
+}
+
+func mustParseTime(t *testing.T, value string) time.Time {
+	t.Helper()
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatalf("parse %s: %v", value, err)
+	}
+	return parsed
+}
+
+func TestParseCronScheduleNext(t *testing.T) {
+	tests := []struct {
+		name     string
+		expr     string
+		timezone string
+		from     string
+		want     []string
+	}{
+		{
+			name:     "fixed hour in winter and summer",
+			expr:     "0 9 * * *",
+			timezone: "Europe/Paris",
+			from:     "2026-03-28T12:00:00Z",
+			want:     []string{"2026-03-29T07:00:00Z", "2026-03-30T07:00:00Z"},
+		},
+		{
+			name:     "spring forward fires after the gap",
+			expr:     "30 2 * * *",
+			timezone: "America/New_York",
+			from:     "2026-03-07T17:00:00Z",
+			want:     []string{"2026-03-08T07:00:00Z", "2026-03-09T06:30:00Z"},
+		},
+		{
+			name:     "fall back fires once at the first occurrence",
+			expr:     "30 1 * * *",
+			timezone: "America/New_York",
+			from:     "2026-10-31T16:00:00Z",
+			want:     []string{"2026-11-01T05:30:00Z", "2026-11-02T06:30:00Z"},
+		},
+		{
+			name:     "every hour follows elapsed time across fall back",
+			expr:     "0 * * * *",
+			timezone: "America/New_York",
+			from:     "2026-11-01T04:30:00Z",
+			want:     []string{"2026-11-01T05:00:00Z", "2026-11-01T06:00:00Z", "2026-11-01T07:00:00Z"},
+		},
+		{
+			name:     "every hour follows elapsed time across spring forward",
+			expr:     "0 * * * *",
+			timezone: "America/New_York",
+			from:     "2026-03-08T06:30:00Z",
+			want:     []string{"2026-03-08T07:00:00Z", "2026-03-08T08:00:00Z"},
+		},
+		{
+			name:     "UTC",
+			expr:     "15 10 1 * *",
+			timezone: "UTC",
+			from:     "2026-01-31T00:00:00Z",
+			want:     []string{"2026-02-01T10:15:00Z", "2026-03-01T10:15:00Z"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := parseCronSchedule(tt.expr, mustLoadLocation(t, tt.timezone))
+			if err != nil {
+				t.Fatalf("parseCronSchedule: %v", err)
+			}
+			next := mustParseTime(t, tt.from)
+			for i, want := range tt.want {
+				next = schedule.Next(next)
+				if !next.Equal(mustParseTime(t, want)) {
+					t.Fatalf("run %d = %s, want %s", i, next.UTC().Format(time.RFC3339), want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseCronScheduleRejectsTimezonePrefix(t *testing.T) {
+	for _, expr := range []string{"CRON_TZ=Europe/Paris 0 9 * * *", "TZ=UTC 0 9 * * *", "  CRON_TZ=UTC 0 9 * * *"} {
+		_, err := parseCronSchedule(expr, time.UTC)
+		if err == nil || !strings.Contains(err.Error(), "job timezone") {
+			t.Errorf("parseCronSchedule(%q) error = %v, want the timezone prefix rejected", expr, err)
+		}
+	}
+}
+
+func TestWallClockInstant(t *testing.T) {
+	tests := []struct {
+		name     string
+		wall     time.Time
+		timezone string
+		want     string
+		exists   bool
+	}{
+		{"regular time", time.Date(2026, 1, 10, 9, 0, 0, 0, time.UTC), "Europe/Paris", "2026-01-10T08:00:00Z", true},
+		{"summer time", time.Date(2026, 7, 10, 9, 0, 0, 0, time.UTC), "Europe/Paris", "2026-07-10T07:00:00Z", true},
+		{"skipped by spring forward", time.Date(2026, 3, 8, 2, 30, 0, 0, time.UTC), "America/New_York", "2026-03-08T07:00:00Z", false},
+		{"repeated by fall back", time.Date(2026, 11, 1, 1, 30, 0, 0, time.UTC), "America/New_York", "2026-11-01T05:30:00Z", true},
+		{"half hour offset gap", time.Date(2026, 10, 4, 2, 15, 0, 0, time.UTC), "Australia/Lord_Howe", "2026-10-03T15:30:00Z", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, exists := wallClockInstant(tt.wall, mustLoadLocation(t, tt.timezone))
+			if !got.Equal(mustParseTime(t, tt.want)) || exists != tt.exists {
+				t.Errorf("wallClockInstant = %s, %v, want %s, %v", got.UTC().Format(time.RFC3339), exists, tt.want, tt.exists)
+			}
+		})
+	}
+}
+
+func TestAddMonthsClamped(t *testing.T) {
+	tests := []struct {
+		from   string
+		months int
+		want   string
+	}{
+		{"2026-01-15T09:00:00Z", 1, "2026-02-15T09:00:00Z"},
+		{"2026-01-31T09:00:00Z", 1, "2026-02-28T09:00:00Z"},
+		{"2028-01-31T09:00:00Z", 1, "2028-02-29T09:00:00Z"},
+		{"2026-03-31T09:00:00Z", 1, "2026-04-30T09:00:00Z"},
+		{"2026-01-31T09:00:00Z", 2, "2026-03-31T09:00:00Z"},
+		{"2026-12-31T09:00:00Z", 2, "2027-02-28T09:00:00Z"},
+		{"2026-05-31T09:00:00Z", 0, "2026-05-31T09:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		got := addMonthsClamped(mustParseTime(t, tt.from), tt.months)
+		if !got.Equal(mustParseTime(t, tt.want)) {
+			t.Errorf("addMonthsClamped(%s, %d) = %s, want %s", tt.from, tt.months, got.Format(time.RFC3339), tt.want)
+		}
+	}
+}
+
+func TestFixedIntervalScheduleNext(t *testing.T) {
+	tests := []struct {
+		name     string
+		unit     models.IntervalType
+		every    int
+		anchor   string
+		timezone string
+		from     string
+		want     []string
+	}{
+		{
+			name:     "minutes from before the anchor",
+			unit:     models.IntervalTypeMinutes,
+			every:    15,
+			anchor:   "2026-01-10T10:00:00Z",
+			timezone: "UTC",
+			from:     "2026-01-10T09:00:00Z",
+			want:     []string{"2026-01-10T10:00:00Z", "2026-01-10T10:15:00Z"},
+		},
+		{
+			name:     "minutes between occurrences",
+			unit:     models.IntervalTypeMinutes,
+			every:    15,
+			anchor:   "2026-01-10T10:00:00Z",
+			timezone: "UTC",
+			from:     "2026-01-10T10:07:00Z",
+			want:     []string{"2026-01-10T10:15:00Z", "2026-01-10T10:30:00Z"},
+		},
+		{
+			name:     "hours keep elapsed time across spring forward",
+			unit:     models.IntervalTypeHours,
+			every:    6,
+			anchor:   "2026-03-07T14:00:00Z",
+			timezone: "America/New_York",
+			from:     "2026-03-08T03:00:00Z",
+			want:     []string{"2026-03-08T08:00:00Z", "2026-03-08T14:00:00Z"},
+		},
+		{
+			name:     "days keep the wall clock across spring forward",
+			unit:     models.IntervalTypeDays,
+			every:    1,
+			anchor:   "2026-03-07T14:00:00Z",
+			timezone: "America/New_York",
+			from:     "2026-03-07T14:00:00Z",
+			want:     []string{"2026-03-08T13:00:00Z", "2026-03-09T13:00:00Z"},
+		},
+		{
+			name:     "days keep the wall clock across fall back",
+			unit:     models.IntervalTypeDays,
+			every:    2,
+			anchor:   "2026-10-31T13:00:00Z",
+			timezone: "America/New_York",
+			from:     "2026-10-31T13:00:00Z",
+			want:     []string{"2026-11-02T14:00:00Z", "2026-11-04T14:00:00Z"},
+		},
+		{
+			name:     "months run on the last day of shorter months",
+			unit:     models.IntervalTypeMonths,
+			every:    1,
+			anchor:   "2026-01-31T09:00:00Z",
+			timezone: "UTC",
+			from:     "2026-01-31T09:00:00Z",
+			want:     []string{"2026-02-28T09:00:00Z", "2026-03-31T09:00:00Z", "2026-04-30T09:00:00Z"},
+		},
+		{
+			name:     "months skip from far after the anchor",
+			unit:     models.IntervalTypeMonths,
+			every:    3,
+			anchor:   "2025-11-30T09:00:00Z",
+			timezone: "UTC",
+			from:     "2026-06-01T00:00:00Z",
+			want:     []string{"2026-08-30T09:00:00Z", "2026-11-30T09:00:00Z"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			value := strconv.Itoa(tt.every)
+			anchor := tt.anchor
+			schedule, err := newIntervalSchedule(models.IntervalData{IntervalType: tt.unit, Value: &value, StartAt: &anchor}, time.Time{}, mustLoadLocation(t, tt.timezone))
+			if err != nil {
+				t.Fatalf("newIntervalSchedule: %v", err)
+			}
+			next := mustParseTime(t, tt.from)
+			for i, want := range tt.want {
+				next = schedule.Next(next)
+				if !next.Equal(mustParseTime(t, want)) {
+					t.Fatalf("run %d = %s, want %s", i, next.UTC().Format(time.RFC3339), want)
+				}
+			}
+		})
+	}
+}
+
+func TestNextRunTimes(t *testing.T) {
+	interval := `{"interval_type": "cron", "value": "30 2 * * *"}`
+	endsAt := mustParseTime(t, "2026-03-11T00:00:00Z")
+	maxRuns := 2
+
+	tests := []struct {
+		name string
+		job  models.Jobs
+		want []string
+	}{
+		{
+			name: "cron interval across spring forward",
+			job:  models.Jobs{Type: models.JobTypeInterval, Interval: &interval, Timezone: "America/New_York"},
+			want: []string{"2026-03-08T03:00:00-04:00", "2026-03-09T02:30:00-04:00", "2026-03-10T02:30:00-04:00", "2026-03-11T02:30:00-04:00"},
+		},
+		{
+			name: "ends_at",
+			job:  models.Jobs{Type: models.JobTypeInterval, Interval: &interval, Timezone: "America/New_York", EndsAt: &endsAt},
+			want: []string{"2026-03-08T03:00:00-04:00", "2026-03-09T02:30:00-04:00", "2026-03-10T02:30:00-04:00"},
+		},
+		{
+			name: "max_runs",
+			job:  models.Jobs{Type: models.JobTypeInterval, Interval: &interval, Timezone: "America/New_York", MaxRuns: &maxRuns, RunCount: 1},
+			want: []string{"2026-03-08T03:00:00-04:00"},
+		},
+		{
+			name: "dependent job",
+			job:  models.Jobs{Type: models.JobTypeDependent, Timezone: "UTC"},
+			want: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runs, suppressed, err := nextRunTimes(&tt.job, mustParseTime(t, "2026-03-07T12:00:00Z"), 4, nil)
+			if err != nil {
+				t.Fatalf("nextRunTimes: %v", err)
+			}
+			if len(suppressed) != 0 {
+				t.Errorf("suppressed = %v, want none", suppressed)
+			}
+			got := make([]string, len(runs))
+			for i, run := range runs {
+				got[i] = run.Format(time.RFC3339)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("runs = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
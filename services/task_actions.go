@@ -0,0 +1,197 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"gin-gorm-river-app/models"
+	"gin-gorm-river-app/shared"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/riverqueue/river"
+)
+
+var (
+	// ErrTaskNotResumable is returned when a task did not fail or has no plan step left to run again
+	ErrTaskNotResumable = errors.New("task has no failed agent plan to resume")
+	// ErrTaskNotRetryable is returned when a task has not finished, or was skipped and never ran its payload
+	ErrTaskNotRetryable = errors.New("task has not finished running its payload")
+	// ErrTaskNotCancelable is returned when a task has already finished
+	ErrTaskNotCancelable = errors.New("task has already finished")
+)
+
+var (
+	// resumableTaskStatuses are the final statuses of a task whose plan may be resumed
+	resumableTaskStatuses = []models.TaskStatus{models.TaskStatusPartiallyCompleted, models.TaskStatusFailed, models.TaskStatusTimedOut, models.TaskStatusCanceled}
+	// retryableTaskStatuses are the final statuses of a task that ran its payload
+	retryableTaskStatuses = []models.TaskStatus{models.TaskStatusCompleted, models.TaskStatusPartiallyCompleted, models.TaskStatusFailed, models.TaskStatusTimedOut, models.TaskStatusCanceled}
+	// cancelableTaskStatuses are the statuses of a task that has not finished
+	cancelableTaskStatuses = []models.TaskStatus{models.TaskStatusCreated, models.TaskStatusRunning, models.TaskStatusRetrying}
+)
+
+// agentCancelTimeout bounds each tasks/cancel call sent to an agent when a task is cancelled
+const agentCancelTimeout = 10 * time.Second
+
+// getOwnedTask returns a non-deleted task of a job owned by the user, with its job
+func (s *JobService) getOwnedTask(ctx context.Context, taskID uuid.UUID, userId uuid.UUID) (*models.Tasks, *models.Jobs, error) {
+	task, err := NewTasksService(s.db).GetTaskByID(taskID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if task == nil {
+		return nil, nil, ErrTaskNotFound
+	}
+	job, err := s.GetOwnedJob(ctx, task.JobID, userId)
+	if err != nil {
+		if errors.Is(err, ErrJobNotFound) {
+			return nil, nil, ErrTaskNotFound
+		}
+		return nil, nil, err
+	}
+	return task, job, nil
+}
+
+// GetTask returns a task of a job owned by the user
+func (s *JobService) GetTask(ctx context.Context, taskID uuid.UUID, userId uuid.UUID) (*models.Tasks, error) {
+	task, _, err := s.getOwnedTask(ctx, taskID, userId)
+	return task, err
+}
+
+// RetryTask enqueues a run of the payload rendered for a finished task. The run creates a new task
+// whose retry_of_task_id links it to the original.
+func (s *JobService) RetryTask(ctx context.Context, taskID uuid.UUID, userId uuid.UUID) (int64, error) {
+	task, job, err := s.getOwnedTask(ctx, taskID, userId)
+	if err != nil {
+		return 0, err
+	}
+	if job.Status != models.JobStatusActive {
+		return 0, ErrJobNotActive
+	}
+	if !slices.Contains(retryableTaskStatuses, task.Status) {
+		return 0, ErrTaskNotRetryable
+	}
+
+	return GetRiverClientInstance(s.db).EnqueueRetryRun(ctx, job, task)
+}
+
+// CancelTask stops a task that has not finished. Cancelling its River job cancels the context of a
+// running attempt, and the A2A tasks in flight are cancelled on their agents. A task waiting to
+// start or to be retried is marked canceled right away.
+func (s *JobService) CancelTask(ctx context.Context, taskID uuid.UUID, userId uuid.UUID) error {
+	task, _, err := s.getOwnedTask(ctx, taskID, userId)
+	if err != nil {
+		return err
+	}
+	if !slices.Contains(cancelableTaskStatuses, task.Status) {
+		return ErrTaskNotCancelable
+	}
+
+	if task.RiverJobID != 0 {
+		if _, err := GetRiverClientInstance(s.db).Client.JobCancel(ctx, task.RiverJobID); err != nil && !errors.Is(err, river.ErrNotFound) {
+			return err
+		}
+	}
+
+	tasksService := NewTasksService(s.db)
+	if task.Status == models.TaskStatusRunning {
+		// The worker records the cancellation once its attempt returns
+		s.cancelAgentTasks(ctx, task, tasksService)
+		return nil
+	}
+	_, err = tasksService.CancelPendingTask(task.ID)
+	return err
+}
+
+// cancelAgentTasks sends tasks/cancel for the A2A task of a running task and for those of its running
+// plan steps. The worker does the same when its context is cancelled; sending it from here too stops
+// the agents even when the worker running the task is gone. Failures are only logged.
+func (s *JobService) cancelAgentTasks(ctx context.Context, task *models.Tasks, tasksService *TasksService) {
+	client := shared.NewAIAgentClient()
+	cancel := func(agentURL string, agentTaskID string) {
+		cancelCtx, cancelRequest := context.WithTimeout(ctx, agentCancelTimeout)
+		defer cancelRequest()
+		if _, err := client.CancelTask(cancelCtx, agentURL, agentTaskID); err != nil {
+			log.Printf("Failed to cancel agent task %s of task %s: %v", agentTaskID, task.ID, err)
+		}
+	}
+
+	if task.AgentURL != "" && task.AgentTaskID != "" {
+		cancel(task.AgentURL, task.AgentTaskID)
+	}
+
+	steps, err := tasksService.GetTaskSteps(task.ID)
+	if err != nil {
+		log.Printf("Failed to load steps of task %s to cancel: %v", task.ID, err)
+		return
+	}
+	for _, step := range steps {
+		if step.Status == models.TaskStatusRunning && step.AgentTaskID != "" {
+			cancel(step.AgentAddress+"/messages", step.AgentTaskID)
+		}
+	}
+}
+
+// ResumeTask enqueues a new attempt of a task whose agent plan failed. The attempt reuses the
+// outputs of the steps that completed and runs again only the steps that did not.
+func (s *JobService) ResumeTask(ctx context.Context, taskID uuid.UUID, userId uuid.UUID) (int64, error) {
+	task, job, err := s.getOwnedTask(ctx, taskID, userId)
+	if err != nil {
+		return 0, err
+	}
+	if job.Status != models.JobStatusActive {
+		return 0, ErrJobNotActive
+	}
+
+	tasksService := NewTasksService(s.db)
+	steps, err := tasksService.GetTaskSteps(task.ID)
+	if err != nil {
+		return 0, err
+	}
+	pending := false
+	for _, step := range steps {
+		if step.Status != models.TaskStatusCompleted {
+			pending = true
+			break
+		}
+	}
+	if !pending {
+		return 0, ErrTaskNotResumable
+	}
+
+	tx, err := s.db.Pool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Moving the task out of its final status makes a second resume request fail
+	query := `UPDATE tasks SET status = $1, updated_at = $2 WHERE id = $3 AND status = ANY($4) AND is_deleted = false`
+	statuses := make([]string, 0, len(resumableTaskStatuses))
+	for _, status := range resumableTaskStatuses {
+		statuses = append(statuses, string(status))
+	}
+	tag, err := tx.Exec(ctx, query, models.TaskStatusRetrying, time.Now(), task.ID, statuses)
+	if err != nil {
+		return 0, err
+	}
+	if tag.RowsAffected() == 0 {
+		return 0, ErrTaskNotResumable
+	}
+
+	riverJobID, err := GetRiverClientInstance(s.db).EnqueueResumeRunTx(ctx, tx, job, task)
+	if err != nil {
+		return 0, err
+	}
+	// The task now runs under the resume's River job, which is the one a cancel stops
+	if _, err := tx.Exec(ctx, `UPDATE tasks SET river_job_id = $1 WHERE id = $2`, riverJobID, task.ID); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, err
+	}
+
+	tasksService.notifyTaskEvent(task.ID, TaskEventStatus)
+	return riverJobID, nil
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"gin-gorm-river-app/config"
+	"gin-gorm-river-app/models"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TaskEventsChannel is the Postgres NOTIFY channel used for task state changes
+const TaskEventsChannel = "task_events"
+
+// Task event kinds
+const (
+	TaskEventStatus        = "status"
+	TaskEventPartialResult = "partial_result"
+)
+
+// TaskEvent is the payload sent on TaskEventsChannel.
+// It only carries identifiers: NOTIFY payloads are limited to 8000 bytes,
+// so listeners load the task row to get the result.
+type TaskEvent struct {
+	Event  string            `json:"event"`
+	TaskID uuid.UUID         `json:"task_id"`
+	JobID  uuid.UUID         `json:"job_id"`
+	Status models.TaskStatus `json:"status"`
+}
+
+// listenRetryInterval is the wait before re-establishing a lost LISTEN connection
+const listenRetryInterval = 5 * time.Second
+
+// TaskEventBroker holds a single LISTEN connection and fans task events out to subscribers by job
+type TaskEventBroker struct {
+	pool        *pgxpool.Pool
+	mu          sync.Mutex
+	subscribers map[uuid.UUID]map[chan TaskEvent]struct{}
+}
+
+func NewTaskEventBroker(db *config.Database) *TaskEventBroker {
+	return &TaskEventBroker{
+		pool:        db.Pool,
+		subscribers: make(map[uuid.UUID]map[chan TaskEvent]struct{}),
+	}
+}
+
+// Start listens for task events in the background until ctx is cancelled
+func (b *TaskEventBroker) Start(ctx context.Context) {
+	go func() {
+		for {
+			err := b.listen(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Task event listener stopped, reconnecting in %s: %v", listenRetryInterval, err)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(listenRetryInterval):
+			}
+		}
+	}()
+}
+
+// Subscribe returns a channel receiving the events of one job and a function to unsubscribe
+func (b *TaskEventBroker) Subscribe(jobID uuid.UUID) (<-chan TaskEvent, func()) {
+	ch := make(chan TaskEvent, 16)
+
+	b.mu.Lock()
+	if b.subscribers[jobID] == nil {
+		b.subscribers[jobID] = make(map[chan TaskEvent]struct{})
+	}
+	b.subscribers[jobID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers[jobID], ch)
+		if len(b.subscribers[jobID]) == 0 {
+			delete(b.subscribers, jobID)
+		}
+	}
+	return ch, unsubscribe
+}
+
+func (b *TaskEventBroker) listen(ctx context.Context) error {
+	conn, err := b.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		// Do not hand a listening connection back to the pool: it is released once UNLISTEN
+		// succeeded, closed otherwise
+		unlistenCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if _, err := conn.Exec(unlistenCtx, "UNLISTEN *"); err != nil {
+			_ = conn.Hijack().Close(unlistenCtx)
+			return
+		}
+		conn.Release()
+	}()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+TaskEventsChannel); err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var event TaskEvent
+		if err := json.Unmarshal([]byte(notification.Payload), &event); err != nil {
+			log.Printf("Failed to unmarshal task event %q: %v", notification.Payload, err)
+			continue
+		}
+		b.publish(event)
+	}
+}
+
+func (b *TaskEventBroker) publish(event TaskEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers[event.JobID] {
+		select {
+		case ch <- event:
+		default:
+			// Slow subscriber: drop the event rather than blocking every other stream
+		}
+	}
+}
@@ -4,10 +4,12 @@ import (
 	"fmt"
 	"gin-gorm-river-app/config"
 	"gin-gorm-river-app/models"
+	"gin-gorm-river-app/shared"
 	"log"
 	"time"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type TasksService struct {
@@ -20,19 +22,42 @@ func NewTasksService(db *config.Database) *TasksService {
 	}
 }
 
-func (s *TasksService) CreateTask(jobID uuid.UUID, payload string) (uuid.UUID, error) {
+type CreateTaskRequest struct {
+	JobID          uuid.UUID
+	Payload        string
+	Prompt         string // Rendered prompt of the run
+	Trigger        models.TaskTrigger
+	RiverJobID     int64
+	UpstreamTaskID *uuid.UUID
+	RetryOfTaskID  *uuid.UUID
+	Request        *models.WebhookRequest
+	ScheduledFor   *time.Time
+}
+
+func (s *TasksService) CreateTask(req *CreateTaskRequest) (uuid.UUID, error) {
+	jobID := req.JobID
+	if req.Trigger == "" {
+		req.Trigger = models.TaskTriggerSchedule
+	}
 
 	taskID := uuid.New()
 	task := models.Tasks{
-		ID:        taskID,
-		JobID:     jobID,
-		Payload:   payload,
-		Status:    models.TaskStatusCreated,
-		Result:    "", // Initialize empty result
-		IsDeleted: false,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-		Version:   1,
+		ID:             taskID,
+		JobID:          jobID,
+		Payload:        req.Payload,
+		Prompt:         req.Prompt,
+		Trigger:        req.Trigger,
+		UpstreamTaskID: req.UpstreamTaskID,
+		RetryOfTaskID:  req.RetryOfTaskID,
+		Request:        req.Request,
+		ScheduledFor:   req.ScheduledFor,
+		Status:         models.TaskStatusCreated,
+		RiverJobID:     req.RiverJobID,
+		Result:         "", // Initialize empty result
+		IsDeleted:      false,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+		Version:        1,
 	}
 
 	if s.db == nil || s.db.GORM == nil {
@@ -46,6 +71,7 @@ func (s *TasksService) CreateTask(jobID uuid.UUID, payload string) (uuid.UUID, e
 		return uuid.Nil, result.Error
 	}
 
+	s.notifyTaskEvent(taskID, TaskEventStatus)
 	return taskID, nil
 }
 
@@ -66,6 +92,7 @@ func (s *TasksService) UpdateTaskById(taskID uuid.UUID, status models.TaskStatus
 		return fmt.Errorf("task with task ID %s not found", taskID)
 	}
 
+	s.notifyTaskEvent(taskID, TaskEventStatus)
 	return nil
 }
 
@@ -87,9 +114,87 @@ func (s *TasksService) UpdateTaskResult(taskID uuid.UUID, result string, status
 		return fmt.Errorf("task with job ID %s not found", taskID)
 	}
 
+	s.notifyTaskEvent(taskID, TaskEventStatus)
+	return nil
+}
+
+// UpdateTaskPartialResult stores streamed output on a task that is still running
+func (s *TasksService) UpdateTaskPartialResult(taskID uuid.UUID, partial string) error {
+	result := s.db.GORM.Model(&models.Tasks{}).
+		Where("id = ? AND status = ?", taskID, models.TaskStatusRunning).
+		Updates(map[string]interface{}{
+			"result":     partial,
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected > 0 {
+		s.notifyTaskEvent(taskID, TaskEventPartialResult)
+	}
 	return nil
 }
 
+// SetTaskResult stores the result of a running task without changing its status, which the worker
+// updates once the attempt returns
+func (s *TasksService) SetTaskResult(taskID uuid.UUID, result string) error {
+	return s.db.GORM.Model(&models.Tasks{}).
+		Where("id = ? AND status = ?", taskID, models.TaskStatusRunning).
+		Updates(map[string]interface{}{
+			"result":     result,
+			"updated_at": time.Now(),
+		}).Error
+}
+
+// notifyTaskEvent publishes the task's current state on TaskEventsChannel.
+// Failures are only logged: listeners are best effort and must never fail a job.
+func (s *TasksService) notifyTaskEvent(taskID uuid.UUID, event string) {
+	query := `SELECT pg_notify($1, json_build_object('event', $2::text, 'task_id', id, 'job_id', job_id, 'status', status)::text) FROM tasks WHERE id = $3`
+	if err := s.db.GORM.Exec(query, TaskEventsChannel, event, taskID).Error; err != nil {
+		log.Printf("Failed to notify %s event for task %s: %v", event, taskID, err)
+	}
+}
+
+// SetTaskAgent records the A2A task that runs the current attempt of a task
+func (s *TasksService) SetTaskAgent(taskID uuid.UUID, agentURL string, agentTaskID string) error {
+	return s.db.GORM.Model(&models.Tasks{}).
+		Where("id = ?", taskID).
+		Updates(map[string]interface{}{
+			"agent_url":     agentURL,
+			"agent_task_id": agentTaskID,
+			"updated_at":    time.Now(),
+		}).Error
+}
+
+// CancelPendingTask marks canceled a task that is waiting to start or to be retried.
+// It reports whether the task was still pending.
+func (s *TasksService) CancelPendingTask(taskID uuid.UUID) (bool, error) {
+	result := s.db.GORM.Model(&models.Tasks{}).
+		Where("id = ? AND status IN ?", taskID, []models.TaskStatus{models.TaskStatusCreated, models.TaskStatusRetrying}).
+		Updates(map[string]interface{}{
+			"status":     models.TaskStatusCanceled,
+			"updated_at": time.Now(),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+	s.notifyTaskEvent(taskID, TaskEventStatus)
+	return true, nil
+}
+
+// GetTaskByJobID returns a task of the given job
+func (s *TasksService) GetTaskByJobID(taskID uuid.UUID, jobID uuid.UUID) (*models.Tasks, error) {
+	task := &models.Tasks{}
+	if err := s.db.GORM.Where("id = ? AND job_id = ? AND is_deleted = false", taskID, jobID).First(task).Error; err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
 // RecoverRunningTasks recovers tasks that were running when server restarted
 func (s *TasksService) RecoverRunningTasks() error {
 	// Find all tasks that were running when server restarted
@@ -108,7 +213,7 @@ func (s *TasksService) RecoverRunningTasks() error {
 
 	// Group tasks by job ID to efficiently clear current_task_id
 	jobIDs := make(map[uuid.UUID]bool)
-	
+
 	// Reset all running tasks to created status so they can be re-executed
 	for _, task := range runningTasks {
 		updateResult := s.db.GORM.Model(&models.Tasks{}).
@@ -148,17 +253,220 @@ func (s *TasksService) RecoverRunningTasks() error {
 	return nil
 }
 
+// GetTaskByRiverJobID returns the task created by a River job, or nil if there is none yet
+func (s *TasksService) GetTaskByRiverJobID(riverJobID int64) (*models.Tasks, error) {
+	var tasks []models.Tasks
+	result := s.db.GORM.Where("river_job_id = ? AND is_deleted = false", riverJobID).
+		Order("created_at DESC").
+		Limit(1).
+		Find(&tasks)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to fetch task of River job %d: %w", riverJobID, result.Error)
+	}
+	if len(tasks) == 0 {
+		return nil, nil
+	}
+	return &tasks[0], nil
+}
+
+// GetTaskByID returns a non-deleted task, or nil if it does not exist
+func (s *TasksService) GetTaskByID(taskID uuid.UUID) (*models.Tasks, error) {
+	var tasks []models.Tasks
+	result := s.db.GORM.Where("id = ? AND is_deleted = false", taskID).Limit(1).Find(&tasks)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to fetch task %s: %w", taskID, result.Error)
+	}
+	if len(tasks) == 0 {
+		return nil, nil
+	}
+	return &tasks[0], nil
+}
+
+// CountRunsByJobID returns the number of tasks of a job that were not skipped
+func (s *TasksService) CountRunsByJobID(jobID uuid.UUID) (int64, error) {
+	var count int64
+	result := s.db.GORM.Model(&models.Tasks{}).
+		Where("job_id = ? AND status <> ? AND is_deleted = false", jobID, models.TaskStatusSkipped).
+		Count(&count)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to count tasks of job %s: %w", jobID, result.Error)
+	}
+	return count, nil
+}
+
+// GetPreviousTask returns the latest finished task of a job, or nil if none finished yet
+func (s *TasksService) GetPreviousTask(jobID uuid.UUID) (*models.Tasks, error) {
+	var tasks []models.Tasks
+	finished := []models.TaskStatus{models.TaskStatusCompleted, models.TaskStatusPartiallyCompleted, models.TaskStatusFailed, models.TaskStatusTimedOut, models.TaskStatusCanceled}
+	result := s.db.GORM.Where("job_id = ? AND status IN ? AND is_deleted = false", jobID, finished).
+		Order("created_at DESC").
+		Limit(1).
+		Find(&tasks)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to fetch previous task of job %s: %w", jobID, result.Error)
+	}
+	if len(tasks) == 0 {
+		return nil, nil
+	}
+	return &tasks[0], nil
+}
+
+// GetLastAttemptError returns the error of the latest attempt of a task, or "" when it has none
+func (s *TasksService) GetLastAttemptError(taskID uuid.UUID) (string, error) {
+	var attempts []models.TaskAttempts
+	result := s.db.GORM.Where("task_id = ?", taskID).Order("attempt DESC").Limit(1).Find(&attempts)
+	if result.Error != nil {
+		return "", fmt.Errorf("failed to fetch attempts of task %s: %w", taskID, result.Error)
+	}
+	if len(attempts) == 0 {
+		return "", nil
+	}
+	return attempts[0].Error, nil
+}
+
+// CreateTaskAttempt records the start of an execution attempt and marks the task with it
+func (s *TasksService) CreateTaskAttempt(taskID uuid.UUID, attempt int) (uuid.UUID, error) {
+	now := time.Now()
+	taskAttempt := models.TaskAttempts{
+		ID:        uuid.New(),
+		TaskID:    taskID,
+		Attempt:   attempt,
+		Status:    models.TaskStatusRunning,
+		StartedAt: now,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.db.GORM.Create(&taskAttempt).Error; err != nil {
+		return uuid.Nil, fmt.Errorf("failed to create attempt %d of task %s: %w", attempt, taskID, err)
+	}
+
+	if err := s.db.GORM.Model(&models.Tasks{}).
+		Where("id = ?", taskID).
+		Updates(map[string]interface{}{
+			"attempt":    attempt,
+			"updated_at": now,
+		}).Error; err != nil {
+		return uuid.Nil, err
+	}
+	return taskAttempt.ID, nil
+}
+
+// FinishTaskAttempt stores the outcome of an execution attempt
+func (s *TasksService) FinishTaskAttempt(attemptID uuid.UUID, status models.TaskStatus, runErr error, class models.ErrorClass) error {
+	now := time.Now()
+	updates := map[string]interface{}{
+		"status":      status,
+		"finished_at": now,
+		"updated_at":  now,
+	}
+	if runErr != nil {
+		updates["error"] = runErr.Error()
+		updates["error_class"] = class
+	}
+	return s.db.GORM.Model(&models.TaskAttempts{}).Where("id = ?", attemptID).Updates(updates).Error
+}
+
+// GetRunningTasksByJobID returns the tasks of a job that are currently running
+func (s *TasksService) GetRunningTasksByJobID(jobID uuid.UUID) ([]models.Tasks, error) {
+	var tasks []models.Tasks
+	result := s.db.GORM.Where("job_id = ? AND status = ? AND is_deleted = false", jobID, models.TaskStatusRunning).
+		Order("created_at ASC").
+		Find(&tasks)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to fetch running tasks for job %s: %w", jobID, result.Error)
+	}
+	return tasks, nil
+}
+
 // ✅ ADD: Get incomplete tasks by job ID
 func (s *TasksService) GetIncompleteTasksByJobID(jobID uuid.UUID) ([]models.Tasks, error) {
 	var tasks []models.Tasks
-	result := s.db.GORM.Where("job_id = ? AND status IN (?, ?) AND is_deleted = false", 
+	result := s.db.GORM.Where("job_id = ? AND status IN (?, ?) AND is_deleted = false",
 		jobID, models.TaskStatusCreated, models.TaskStatusRunning).
 		Order("created_at ASC").
 		Find(&tasks)
-	
+
 	if result.Error != nil {
 		return nil, fmt.Errorf("failed to fetch incomplete tasks for job %s: %w", jobID, result.Error)
 	}
-	
+
 	return tasks, nil
 }
+
+// CreateTaskSteps replaces the steps of a task with the steps of a plan, all in created status.
+// It returns the ID of each step row by plan task ID.
+func (s *TasksService) CreateTaskSteps(taskID uuid.UUID, attempt int, plan []shared.IAgentTask) (map[string]uuid.UUID, error) {
+	now := time.Now()
+	steps := make([]models.TaskSteps, 0, len(plan))
+	stepIDs := map[string]uuid.UUID{}
+	for _, planStep := range plan {
+		step := models.TaskSteps{
+			ID:           uuid.New(),
+			TaskID:       taskID,
+			Step:         planStep.Step,
+			PlanTaskID:   planStep.TaskID,
+			Dependencies: planStep.Dependencies,
+			AgentName:    planStep.AgentName,
+			AgentAddress: planStep.AgentAddress,
+			Task:         planStep.Task,
+			Status:       models.TaskStatusCreated,
+			Attempt:      attempt,
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		}
+		stepIDs[planStep.TaskID] = step.ID
+		steps = append(steps, step)
+	}
+
+	err := s.db.GORM.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("task_id = ?", taskID).Delete(&models.TaskSteps{}).Error; err != nil {
+			return err
+		}
+		if len(steps) == 0 {
+			return nil
+		}
+		return tx.Create(&steps).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create steps of task %s: %w", taskID, err)
+	}
+	return stepIDs, nil
+}
+
+// StartTaskStep marks a step running with the message sent to its agent as the A2A task agentTaskID
+func (s *TasksService) StartTaskStep(stepID uuid.UUID, attempt int, input string, agentTaskID string) error {
+	now := time.Now()
+	return s.db.GORM.Model(&models.TaskSteps{}).Where("id = ?", stepID).Updates(map[string]interface{}{
+		"status":        models.TaskStatusRunning,
+		"attempt":       attempt,
+		"input":         input,
+		"agent_task_id": agentTaskID,
+		"output":        "",
+		"error":         "",
+		"started_at":    now,
+		"finished_at":   nil,
+		"updated_at":    now,
+	}).Error
+}
+
+// FinishTaskStep records the outcome of a step
+func (s *TasksService) FinishTaskStep(stepID uuid.UUID, status models.TaskStatus, output string, stepErr string) error {
+	now := time.Now()
+	return s.db.GORM.Model(&models.TaskSteps{}).Where("id = ?", stepID).Updates(map[string]interface{}{
+		"status":      status,
+		"output":      output,
+		"error":       stepErr,
+		"finished_at": now,
+		"updated_at":  now,
+	}).Error
+}
+
+// GetTaskSteps returns the steps of a task ordered by step number
+func (s *TasksService) GetTaskSteps(taskID uuid.UUID) ([]models.TaskSteps, error) {
+	steps := []models.TaskSteps{}
+	result := s.db.GORM.Where("task_id = ?", taskID).Order("step ASC, created_at ASC").Find(&steps)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to fetch steps of task %s: %w", taskID, result.Error)
+	}
+	return steps, nil
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidRequest is matched by validation errors of requests other than jobs
+var ErrInvalidRequest = errors.New("invalid request")
+
+// FieldError describes one invalid field of a request
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists the invalid fields of a request. It matches Err, ErrInvalidRequest
+// when nil, which also prefixes its message.
+type ValidationError struct {
+	Err    error        `json:"-"`
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, fieldErr := range e.Errors {
+		messages[i] = fieldErr.Field + ": " + fieldErr.Message
+	}
+	return e.kind().Error() + ": " + strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == e.kind()
+}
+
+func (e *ValidationError) kind() error {
+	if e.Err == nil {
+		return ErrInvalidRequest
+	}
+	return e.Err
+}
+
+func (e *ValidationError) add(field string, format string, args ...interface{}) {
+	e.Errors = append(e.Errors, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// withoutField returns err without the errors of field, nil when none is left
+func withoutField(err error, field string) error {
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		return err
+	}
+	kept := &ValidationError{Err: verr.Err}
+	for _, fieldErr := range verr.Errors {
+		if fieldErr.Field != field {
+			kept.Errors = append(kept.Errors, fieldErr)
+		}
+	}
+	if len(kept.Errors) == 0 {
+		return nil
+	}
+	return kept
+}
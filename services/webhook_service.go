@@ -0,0 +1,123 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gin-gorm-river-app/config"
+	"gin-gorm-river-app/models"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/time/rate"
+	"gorm.io/gorm"
+)
+
+// Webhook errors
+var (
+	ErrWebhookNotFound    = errors.New("webhook not found")
+	ErrWebhookSignature   = errors.New("invalid webhook signature")
+	ErrWebhookRateLimited = errors.New("webhook rate limit exceeded")
+)
+
+// Hook request limits
+const (
+	MaxWebhookBodySize = 1 << 20
+	// WebhookSignatureTolerance is how far X-Webhook-Timestamp may be from the server clock,
+	// older signed requests cannot be replayed
+	WebhookSignatureTolerance = 5 * time.Minute
+)
+
+// Each hook accepts webhookRate requests per second with bursts of webhookBurst
+const (
+	webhookRate  = rate.Limit(1)
+	webhookBurst = 10
+)
+
+type WebhookService struct {
+	db       *config.Database
+	limiters sync.Map // job ID -> *rate.Limiter
+}
+
+func NewWebhookService(db *config.Database) *WebhookService {
+	return &WebhookService{
+		db: db,
+	}
+}
+
+// TriggerWebhook verifies a request to a hook URL and enqueues a run of its job.
+// The signature is the hex HMAC-SHA256 of "<timestamp>.<body>" keyed by the job's webhook secret.
+func (s *WebhookService) TriggerWebhook(ctx context.Context, token string, timestamp string, signature string, request *models.WebhookRequest, body []byte) (int64, error) {
+	job := &models.Jobs{}
+	err := s.db.GORM.WithContext(ctx).
+		Where("webhook_token = ? AND type = ? AND status = 'active' AND is_deleted = false", token, models.JobTypeWebhook).
+		First(job).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, ErrWebhookNotFound
+		}
+		return 0, err
+	}
+	if job.WebhookSecret == nil {
+		return 0, ErrWebhookNotFound
+	}
+
+	if err := verifyWebhookSignature(*job.WebhookSecret, timestamp, signature, body, request.ReceivedAt); err != nil {
+		return 0, err
+	}
+	if !s.limiter(job.ID).Allow() {
+		return 0, ErrWebhookRateLimited
+	}
+
+	return GetRiverClientInstance(s.db).EnqueueWebhookRun(ctx, job, request, string(body))
+}
+
+// limiter returns the rate limiter of a hook. Only verified jobs get one, so unknown tokens
+// cannot grow the map.
+func (s *WebhookService) limiter(jobID uuid.UUID) *rate.Limiter {
+	limiter, _ := s.limiters.LoadOrStore(jobID, rate.NewLimiter(webhookRate, webhookBurst))
+	return limiter.(*rate.Limiter)
+}
+
+func verifyWebhookSignature(secret string, timestamp string, signature string, body []byte, now time.Time) error {
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: missing or invalid X-Webhook-Timestamp", ErrWebhookSignature)
+	}
+	if skew := now.Sub(time.Unix(seconds, 0)); skew > WebhookSignatureTolerance || skew < -WebhookSignatureTolerance {
+		return fmt.Errorf("%w: timestamp outside of %s", ErrWebhookSignature, WebhookSignatureTolerance)
+	}
+
+	got, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil || len(got) == 0 {
+		return fmt.Errorf("%w: missing or invalid X-Webhook-Signature", ErrWebhookSignature)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	if !hmac.Equal(got, mac.Sum(nil)) {
+		return ErrWebhookSignature
+	}
+	return nil
+}
+
+// newWebhookCredentials returns a random token for the hook URL and a secret signing its requests
+func newWebhookCredentials() (string, string, error) {
+	token := make([]byte, 24)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return "", "", err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), hex.EncodeToString(secret), nil
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gin-gorm-river-app/config"
+	"gin-gorm-river-app/models"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type WorkspaceService struct {
+	db *config.Database
+}
+
+func NewWorkspaceService(db *config.Database) *WorkspaceService {
+	return &WorkspaceService{
+		db: db,
+	}
+}
+
+// GetWorkspaceSettings returns the user's settings for a workspace, defaults when none were saved
+func (s *WorkspaceService) GetWorkspaceSettings(ctx context.Context, workspaceID uuid.UUID, userId uuid.UUID) (*models.WorkspaceSettings, error) {
+	settings := &models.WorkspaceSettings{}
+	err := s.db.GORM.WithContext(ctx).Where("workspace_id = ? AND user_id = ?", workspaceID, userId).First(settings).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.WorkspaceSettings{WorkspaceID: workspaceID, UserID: userId}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch workspace settings: %w", err)
+	}
+	return settings, nil
+}
+
+// UpdateWorkspaceSettings saves the user's settings for a workspace. A new spread applies to the
+// runs of the user's jobs scheduled from now on; runs already queued in River keep their time.
+func (s *WorkspaceService) UpdateWorkspaceSettings(ctx context.Context, workspaceID uuid.UUID, userId uuid.UUID, req *models.UpdateWorkspaceSettingsRequest) (*models.WorkspaceSettings, error) {
+	if err := validateJitterSeconds("spread_seconds", req.SpreadSeconds); err != nil {
+		return nil, &ValidationError{Errors: []FieldError{{Field: "spread_seconds", Message: err.Error()}}}
+	}
+
+	settings, err := s.GetWorkspaceSettings(ctx, workspaceID, userId)
+	if err != nil {
+		return nil, err
+	}
+	if settings.CreatedAt.IsZero() {
+		settings.CreatedAt = time.Now()
+	}
+	settings.SpreadSeconds = req.SpreadSeconds
+	settings.UpdatedAt = time.Now()
+
+	if err := s.db.GORM.WithContext(ctx).Save(settings).Error; err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
@@ -2,9 +2,11 @@ package shared
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"time"
 )
@@ -44,20 +46,59 @@ type TaskStatus struct {
 	Timestamp string   `json:"timestamp,omitempty"`
 }
 
+type Artifact struct {
+	Name        string                 `json:"name,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Parts       []TextPart             `json:"parts"`
+	Metadata    map[string]interface{} `json:"metadata,omitempty"`
+	Index       int                    `json:"index"`
+	Append      *bool                  `json:"append,omitempty"`
+	LastChunk   *bool                  `json:"lastChunk,omitempty"`
+}
+
 type Task struct {
 	ID        string                 `json:"id"`
 	SessionID string                 `json:"sessionId,omitempty"`
 	Status    TaskStatus             `json:"status"`
-	Artifacts []interface{}          `json:"artifacts,omitempty"`
+	Artifacts []Artifact             `json:"artifacts,omitempty"`
 	Metadata  map[string]interface{} `json:"metadata,omitempty"`
 }
 
+// TaskStatusUpdateEvent is streamed by tasks/sendSubscribe when the task state changes
+type TaskStatusUpdateEvent struct {
+	ID       string                 `json:"id"`
+	Status   TaskStatus             `json:"status"`
+	Final    bool                   `json:"final"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// TaskArtifactUpdateEvent is streamed by tasks/sendSubscribe when the agent produces output
+type TaskArtifactUpdateEvent struct {
+	ID       string                 `json:"id"`
+	Artifact Artifact               `json:"artifact"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
 type JSONRPCError struct {
 	Code    int         `json:"code"`
 	Message string      `json:"message"`
 	Data    interface{} `json:"data,omitempty"`
 }
 
+func (e *JSONRPCError) Error() string {
+	return fmt.Sprintf("JSON-RPC error: %d - %s", e.Code, e.Message)
+}
+
+// HTTPStatusError is returned when an agent answers with a non-200 HTTP status
+type HTTPStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("HTTP error: %d - %s", e.StatusCode, e.Body)
+}
+
 type SendTaskResponse struct {
 	JSONRPC string        `json:"jsonrpc"`
 	ID      interface{}   `json:"id"`
@@ -65,22 +106,42 @@ type SendTaskResponse struct {
 	Error   *JSONRPCError `json:"error,omitempty"`
 }
 
+// SendTaskStreamingResponse is the JSON-RPC envelope of a single tasks/sendSubscribe event.
+// Result holds either a TaskStatusUpdateEvent or a TaskArtifactUpdateEvent.
+type SendTaskStreamingResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      interface{}     `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *JSONRPCError   `json:"error,omitempty"`
+}
+
+// JSON-RPC error codes returned by agents that do not implement streaming
+const (
+	JSONRPCMethodNotFound       = -32601
+	JSONRPCUnsupportedOperation = -32004
+)
+
 // AIAgentClient represents a client for calling AI Agent API
 type AIAgentClient struct {
-	HTTPClient  *http.Client
-	BearerToken string // Optional: for authentication if needed
+	HTTPClient       *http.Client
+	StreamHTTPClient *http.Client // No overall timeout: SSE streams stay open for the whole agent run
+	BearerToken      string       // Optional: for authentication if needed
 }
 
 // NewAIAgentClient creates a new AI Agent client
 func NewAIAgentClient() *AIAgentClient {
+	transport := &http.Transport{
+		MaxIdleConns:        100,
+		MaxIdleConnsPerHost: 10,
+		IdleConnTimeout:     2 * time.Minute, // 2 minutes timeout
+	}
 	return &AIAgentClient{
 		HTTPClient: &http.Client{
-			Timeout: 2 * time.Minute, // 2 minutes timeout
-			Transport: &http.Transport{
-				MaxIdleConns:        100,
-				MaxIdleConnsPerHost: 10,
-				IdleConnTimeout:     2 * time.Minute, // 2 minutes timeout
-			},
+			Timeout:   2 * time.Minute, // 2 minutes timeout
+			Transport: transport,
+		},
+		StreamHTTPClient: &http.Client{
+			Transport: transport,
 		},
 	}
 }
@@ -90,9 +151,17 @@ func (c *AIAgentClient) SetBearerToken(token string) {
 	c.BearerToken = token
 }
 
+// Polling backoff used while waiting for an agent task to finish
+const (
+	pollInitialInterval = 1 * time.Second
+	pollMaxInterval     = 30 * time.Second
+)
+
+// cancelRequestTimeout bounds the tasks/cancel call sent after the caller's context is done
+const cancelRequestTimeout = 10 * time.Second
+
 // SendMessage sends a message to an AI agent and returns the final result
-func (c *AIAgentClient) SendMessage(agentURL string, taskID string, userMessage string) (*Task, error) {
-	url := agentURL
+func (c *AIAgentClient) SendMessage(ctx context.Context, agentURL string, taskID string, userMessage string) (*Task, error) {
 	// Create the request payload
 	request := SendTaskRequest{
 		JSONRPC: "2.0",
@@ -112,93 +181,88 @@ func (c *AIAgentClient) SendMessage(agentURL string, taskID string, userMessage
 		},
 	}
 
-	// Marshal request to JSON
-	requestBody, err := json.Marshal(request)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
-	}
+	return c.callJSONRPC(ctx, agentURL, request)
+}
 
-	// Create HTTP request
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBody))
+// SendMessageAndWaitForCompletion sends a message and polls until the task is completed.
+// When ctx is done before the agent finishes, the task is cancelled on the agent.
+func (c *AIAgentClient) SendMessageAndWaitForCompletion(ctx context.Context, agentID, taskID, userMessage string) (*Task, error) {
+	// Send initial message
+	task, err := c.SendMessage(ctx, agentID, taskID, userMessage)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	// Set headers
-	req.Header.Set("Content-Type", "application/json")
-	if c.BearerToken != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.BearerToken))
+		return nil, c.abandonTask(ctx, agentID, taskID, err)
 	}
 
-	// Send request
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+	// If task is already completed, return it
+	if IsTerminalState(task.Status.State) {
+		return task, nil
 	}
-	defer resp.Body.Close()
 
-	// Read response
-	responseBody, err := io.ReadAll(resp.Body)
+	task, err = c.waitForCompletion(ctx, agentID, taskID, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, c.abandonTask(ctx, agentID, taskID, err)
 	}
-
-	// Check HTTP status
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP error: %d - %s", resp.StatusCode, string(responseBody))
-	}
-
-	// Parse response
-	var response SendTaskResponse
-	if err := json.Unmarshal(responseBody, &response); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
-	}
-
-	// Check for JSON-RPC error
-	if response.Error != nil {
-		return nil, fmt.Errorf("JSON-RPC error: %d - %s", response.Error.Code, response.Error.Message)
-	}
-
-	// Return the task result
-	return response.Result, nil
+	return task, nil
 }
 
-// SendMessageAndWaitForCompletion sends a message and polls until the task is completed
-func (c *AIAgentClient) SendMessageAndWaitForCompletion(agentID, taskID, userMessage string) (*Task, error) {
-	// Send initial message
-	task, err := c.SendMessage(agentID, taskID, userMessage)
-	if err != nil {
-		return nil, err
-	}
+// waitForCompletion polls tasks/get with exponential backoff until the task reaches
+// a terminal state or ctx is done. onUpdate, when set, receives every polled snapshot.
+func (c *AIAgentClient) waitForCompletion(ctx context.Context, agentURL, taskID string, onUpdate func(task *Task) error) (*Task, error) {
+	interval := pollInitialInterval
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
 
-	// If task is already completed, return it
-	if task.Status.State == "completed" || task.Status.State == "failed" || task.Status.State == "canceled" {
-		return task, nil
-	}
-
-	// Poll for completion
 	for {
-		time.Sleep(2 * time.Second) // Wait 2 seconds between polls
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
 
 		// Get task status
-		updatedTask, err := c.GetTaskStatus(agentID, taskID)
+		updatedTask, err := c.GetTaskStatus(ctx, agentURL, taskID)
 		if err != nil {
 			return nil, fmt.Errorf("failed to get task status: %w", err)
 		}
 
 		// Check if task is completed
-		if updatedTask.Status.State == "completed" || updatedTask.Status.State == "failed" || updatedTask.Status.State == "canceled" {
+		if IsTerminalState(updatedTask.Status.State) {
 			return updatedTask, nil
 		}
 
-		// Continue polling...
+		if onUpdate != nil {
+			if err := onUpdate(updatedTask); err != nil {
+				return nil, err
+			}
+		}
+
+		interval = min(interval*2, pollMaxInterval)
+		timer.Reset(interval)
 	}
 }
 
-// GetTaskStatus gets the current status of a task
-func (c *AIAgentClient) GetTaskStatus(agentURL string, taskID string) (*Task, error) {
-	url := agentURL
+// abandonTask cancels the agent task when err was caused by ctx being done,
+// so the agent does not keep working on a result nobody will read.
+func (c *AIAgentClient) abandonTask(ctx context.Context, agentURL, taskID string, err error) error {
+	if ctx.Err() == nil {
+		return err
+	}
+
+	cancelCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), cancelRequestTimeout)
+	defer cancel()
+	if _, cancelErr := c.CancelTask(cancelCtx, agentURL, taskID); cancelErr != nil {
+		log.Printf("Failed to cancel agent task %s: %v", taskID, cancelErr)
+	}
+	return fmt.Errorf("agent task %s aborted: %w", taskID, ctx.Err())
+}
+
+// IsTerminalState reports whether an A2A task state is final
+func IsTerminalState(state string) bool {
+	return state == "completed" || state == "failed" || state == "canceled"
+}
 
+// GetTaskStatus gets the current status of a task
+func (c *AIAgentClient) GetTaskStatus(ctx context.Context, agentURL string, taskID string) (*Task, error) {
 	// Create the request payload for getting task status
 	request := map[string]interface{}{
 		"jsonrpc": "2.0",
@@ -209,6 +273,25 @@ func (c *AIAgentClient) GetTaskStatus(agentURL string, taskID string) (*Task, er
 		},
 	}
 
+	return c.callJSONRPC(ctx, agentURL, request)
+}
+
+// CancelTask asks the agent to stop working on a task
+func (c *AIAgentClient) CancelTask(ctx context.Context, agentURL string, taskID string) (*Task, error) {
+	request := map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "tasks/cancel",
+		"params": map[string]interface{}{
+			"id": taskID,
+		},
+	}
+
+	return c.callJSONRPC(ctx, agentURL, request)
+}
+
+// callJSONRPC posts a JSON-RPC request to the agent and returns the resulting task
+func (c *AIAgentClient) callJSONRPC(ctx context.Context, agentURL string, request interface{}) (*Task, error) {
 	// Marshal request to JSON
 	requestBody, err := json.Marshal(request)
 	if err != nil {
@@ -216,7 +299,7 @@ func (c *AIAgentClient) GetTaskStatus(agentURL string, taskID string) (*Task, er
 	}
 
 	// Create HTTP request
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBody))
+	req, err := http.NewRequestWithContext(ctx, "POST", agentURL, bytes.NewBuffer(requestBody))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
@@ -242,7 +325,7 @@ func (c *AIAgentClient) GetTaskStatus(agentURL string, taskID string) (*Task, er
 
 	// Check HTTP status
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("HTTP error: %d - %s", resp.StatusCode, string(responseBody))
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(responseBody)}
 	}
 
 	// Parse response
@@ -253,20 +336,46 @@ func (c *AIAgentClient) GetTaskStatus(agentURL string, taskID string) (*Task, er
 
 	// Check for JSON-RPC error
 	if response.Error != nil {
-		return nil, fmt.Errorf("JSON-RPC error: %d - %s", response.Error.Code, response.Error.Message)
+		return nil, response.Error
 	}
 
 	return response.Result, nil
 }
 
-// ExtractFinalResponse extracts the final text response from a completed task
+// ExtractFinalResponse extracts the final text response from a completed task.
+// Streaming agents usually deliver their output as artifacts, so those are used
+// when the final status message carries no text.
 func ExtractFinalResponse(task *Task) string {
-	if task == nil || task.Status.Message == nil {
+	if task == nil {
 		return ""
 	}
 
 	var result string
-	for _, part := range task.Status.Message.Parts {
+	if task.Status.Message != nil {
+		result = joinTextParts(task.Status.Message.Parts)
+	}
+	if result == "" {
+		result = ExtractArtifactText(task)
+	}
+	return result
+}
+
+// ExtractArtifactText concatenates the text parts of all artifacts of a task
+func ExtractArtifactText(task *Task) string {
+	if task == nil {
+		return ""
+	}
+
+	var result string
+	for _, artifact := range task.Artifacts {
+		result += joinTextParts(artifact.Parts)
+	}
+	return result
+}
+
+func joinTextParts(parts []TextPart) string {
+	var result string
+	for _, part := range parts {
 		if part.Type == "text" {
 			result += part.Text
 		}
@@ -0,0 +1,268 @@
+package shared
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ErrStreamingNotSupported is returned when an agent rejects tasks/sendSubscribe
+var ErrStreamingNotSupported = errors.New("agent does not support streaming")
+
+// SendMessageAndStream sends a message using tasks/sendSubscribe and returns the final task.
+// onUpdate receives the accumulated task every time a status or artifact event arrives.
+// Agents that do not support streaming are transparently handled by polling tasks/get.
+// When ctx is done before the agent finishes, the task is cancelled on the agent.
+func (c *AIAgentClient) SendMessageAndStream(ctx context.Context, agentURL, taskID, userMessage string, onUpdate func(task *Task) error) (*Task, error) {
+	task, err := c.streamOrPoll(ctx, agentURL, taskID, userMessage, onUpdate)
+	if err != nil {
+		return nil, c.abandonTask(ctx, agentURL, taskID, err)
+	}
+	return task, nil
+}
+
+func (c *AIAgentClient) streamOrPoll(ctx context.Context, agentURL, taskID, userMessage string, onUpdate func(task *Task) error) (*Task, error) {
+	task, err := c.SendMessageSubscribe(ctx, agentURL, taskID, userMessage, onUpdate)
+	if errors.Is(err, ErrStreamingNotSupported) {
+		task, err = c.SendMessage(ctx, agentURL, taskID, userMessage)
+		if err != nil {
+			return nil, err
+		}
+		if IsTerminalState(task.Status.State) {
+			return task, nil
+		}
+		if onUpdate != nil {
+			if err := onUpdate(task); err != nil {
+				return nil, err
+			}
+		}
+		return c.waitForCompletion(ctx, agentURL, taskID, onUpdate)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The stream closed before the agent reported a final state: keep polling
+	if !IsTerminalState(task.Status.State) {
+		return c.waitForCompletion(ctx, agentURL, taskID, onUpdate)
+	}
+	return task, nil
+}
+
+// SendMessageSubscribe sends a tasks/sendSubscribe request and consumes the SSE stream
+// until the agent sends a final status event or closes the connection.
+// The returned task is built from the received events and may not be terminal
+// if the stream ended early.
+func (c *AIAgentClient) SendMessageSubscribe(ctx context.Context, agentURL, taskID, userMessage string, onUpdate func(task *Task) error) (*Task, error) {
+	request := SendTaskRequest{
+		JSONRPC: "2.0",
+		ID:      1,
+		Method:  "tasks/sendSubscribe",
+		Params: TaskSendParams{
+			ID: taskID,
+			Message: Message{
+				Role: "user",
+				Parts: []TextPart{
+					{
+						Type: "text",
+						Text: userMessage,
+					},
+				},
+			},
+		},
+	}
+
+	requestBody, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", agentURL, bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	if c.BearerToken != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.BearerToken))
+	}
+
+	resp, err := c.StreamHTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return nil, ErrStreamingNotSupported
+	default:
+		responseBody, _ := io.ReadAll(resp.Body)
+		return nil, &HTTPStatusError{StatusCode: resp.StatusCode, Body: string(responseBody)}
+	}
+
+	task := &Task{ID: taskID}
+
+	// Agents without streaming support answer with a plain JSON-RPC response
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		responseBody, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+		var response SendTaskResponse
+		if err := json.Unmarshal(responseBody, &response); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+		}
+		if response.Error != nil {
+			if isStreamingUnsupportedError(response.Error) {
+				return nil, ErrStreamingNotSupported
+			}
+			return nil, response.Error
+		}
+		if response.Result != nil {
+			task = response.Result
+		}
+		return task, nil
+	}
+
+	errFinal := errors.New("final event received")
+	err = ReadSSEStream(resp.Body, func(message SSEMessage) error {
+		if message.Data == "" {
+			return nil
+		}
+
+		final, err := applyStreamingEvent(task, message.Data)
+		if err != nil {
+			return err
+		}
+		if onUpdate != nil {
+			if err := onUpdate(task); err != nil {
+				return err
+			}
+		}
+		if final {
+			return errFinal
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errFinal) {
+		return nil, err
+	}
+
+	return task, nil
+}
+
+// applyStreamingEvent merges one tasks/sendSubscribe event into task.
+// It reports whether the event was the final status update.
+func applyStreamingEvent(task *Task, data string) (bool, error) {
+	var response SendTaskStreamingResponse
+	if err := json.Unmarshal([]byte(data), &response); err != nil {
+		return false, fmt.Errorf("failed to unmarshal stream event: %w", err)
+	}
+	if response.Error != nil {
+		if isStreamingUnsupportedError(response.Error) {
+			return false, ErrStreamingNotSupported
+		}
+		return false, response.Error
+	}
+
+	var event struct {
+		Status   *TaskStatus `json:"status"`
+		Artifact *Artifact   `json:"artifact"`
+		Final    bool        `json:"final"`
+	}
+	if err := json.Unmarshal(response.Result, &event); err != nil {
+		return false, fmt.Errorf("failed to unmarshal stream event result: %w", err)
+	}
+
+	if event.Status != nil {
+		task.Status = *event.Status
+		return event.Final || IsTerminalState(event.Status.State), nil
+	}
+
+	if event.Artifact != nil {
+		mergeArtifact(task, *event.Artifact)
+	}
+	return false, nil
+}
+
+// mergeArtifact appends chunked artifact parts or replaces the artifact at the same index
+func mergeArtifact(task *Task, artifact Artifact) {
+	for i := range task.Artifacts {
+		if task.Artifacts[i].Index != artifact.Index {
+			continue
+		}
+		if artifact.Append != nil && *artifact.Append {
+			task.Artifacts[i].Parts = append(task.Artifacts[i].Parts, artifact.Parts...)
+			task.Artifacts[i].LastChunk = artifact.LastChunk
+		} else {
+			task.Artifacts[i] = artifact
+		}
+		return
+	}
+	task.Artifacts = append(task.Artifacts, artifact)
+}
+
+func isStreamingUnsupportedError(err *JSONRPCError) bool {
+	return err.Code == JSONRPCMethodNotFound || err.Code == JSONRPCUnsupportedOperation
+}
+
+// ReadSSEStream parses a text/event-stream body and calls handler for every dispatched event.
+// It returns nil when the stream ends and stops early if handler returns an error.
+func ReadSSEStream(body io.Reader, handler SSEHandler) error {
+	reader := bufio.NewReader(body)
+	var message SSEMessage
+	var data []string
+
+	dispatch := func() error {
+		if len(data) == 0 && message.Event == "" {
+			return nil
+		}
+		message.Data = strings.Join(data, "\n")
+		err := handler(message)
+		message = SSEMessage{ID: message.ID}
+		data = nil
+		return err
+	}
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("failed to read stream: %w", err)
+		}
+		eof := err == io.EOF
+
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "":
+			if dispatchErr := dispatch(); dispatchErr != nil {
+				return dispatchErr
+			}
+		case strings.HasPrefix(line, ":"):
+			// Comment / keep-alive
+		default:
+			field, value, _ := strings.Cut(line, ":")
+			value = strings.TrimPrefix(value, " ")
+			switch field {
+			case "id":
+				message.ID = value
+			case "event":
+				message.Event = value
+			case "data":
+				data = append(data, value)
+			}
+		}
+
+		if eof {
+			return dispatch()
+		}
+	}
+}
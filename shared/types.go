@@ -1,22 +1,37 @@
 package shared
 
 import (
+	"gin-gorm-river-app/models"
+	"time"
+
 	"github.com/google/uuid"
 )
 
 type ProcessJobArgs struct {
 	JobID       uuid.UUID `json:"job_id"`
 	TaskID      uuid.UUID `json:"task_id"`
+	Attempt     int       `json:"attempt"`
 	UserID      uuid.UUID `json:"user_id"`
 	WorkspaceID uuid.UUID `json:"workspace_id"`
 	Payload     string    `json:"payload"`
+	Resume      bool      `json:"resume,omitempty"` // Resumes the agent plan recorded for the task
+
+	PlanFailurePolicy models.PlanFailurePolicy `json:"plan_failure_policy,omitempty"`
 }
 
 type IntervalJobArgs struct {
-	JobID       uuid.UUID `json:"job_id"`
-	UserID      uuid.UUID `json:"user_id"`
-	WorkspaceID uuid.UUID `json:"workspace_id"`
-	Payload     string    `json:"payload"`
+	JobID          uuid.UUID              `json:"job_id"`
+	UserID         uuid.UUID              `json:"user_id"`
+	WorkspaceID    uuid.UUID              `json:"workspace_id"`
+	Payload        string                 `json:"payload"`
+	Trigger        string                 `json:"trigger,omitempty"` // models.TaskTrigger, empty means schedule
+	RetryPolicy    *models.RetryPolicy    `json:"retry_policy,omitempty"`
+	UpstreamTaskID *uuid.UUID             `json:"upstream_task_id,omitempty"` // Set on dependency runs
+	Webhook        *models.WebhookRequest `json:"webhook,omitempty"`          // Set on webhook runs
+	WebhookBody    string                 `json:"webhook_body,omitempty"`     // Body of the hook request
+	ResumeTaskID   *uuid.UUID             `json:"resume_task_id,omitempty"`   // Set on runs resuming the plan of a task
+	RetryOfTaskID  *uuid.UUID             `json:"retry_of_task_id,omitempty"` // Set on retry runs of a task
+	ScheduledFor   *time.Time             `json:"scheduled_for,omitempty"`    // Occurrence a scheduled run stands for, before jitter
 }
 
 func (args IntervalJobArgs) Kind() string {